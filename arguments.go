@@ -1,10 +1,13 @@
 package ycat
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"runtime"
 	"strconv"
 	"strings"
 
@@ -26,7 +29,14 @@ func ParseArgs(argv []string, stdin io.Reader, stdout io.WriteCloser) ([]StreamT
 	if err := p.Parse(argv); err != nil {
 		return nil, false, err
 	}
-	return p.Tasks(), p.help, nil
+	if p.help {
+		return nil, true, nil
+	}
+	tasks := p.Tasks()
+	if p.err != nil {
+		return nil, false, p.err
+	}
+	return tasks, false, nil
 }
 
 type argParser struct {
@@ -34,11 +44,35 @@ type argParser struct {
 	stdin  io.Reader
 	stdout io.WriteCloser
 	eval   Eval
+	jq     Query
+	csv    CSVOptions
+	json   JSONOptions
+	text   TextOptions
+	wrap   bool
 	output Output
-	input  Producers
-	tasks  []StreamTask
-	help   bool
-	err    error
+	init   string
+	// initPending is set if --init is not followed by --reduce
+	initPending bool
+	input       Producers
+	tasks       []StreamTask
+	help        bool
+	err         error
+	// Keep going mode
+	errors    *ErrorLog
+	keepGoing bool
+	errorsTo  *os.File
+	// errorsPath is opened when tasks are built so that usage errors do not truncate it
+	errorsPath string
+	// In place mode
+	inPlace    InPlace
+	editFiles  bool
+	transforms []StreamTask
+	// Split output mode
+	split SplitOutput
+	// Directory inputs
+	walk Walk
+	// Last sort stage, consecutive --sort-by options add keys
+	sort *Sort
 }
 
 func (p *argParser) Parse(argv []string) (err error) {
@@ -71,16 +105,95 @@ func (p *argParser) Parse(argv []string) (err error) {
 	return
 }
 func (p *argParser) Tasks() (tasks []StreamTask) {
+	if p.initPending {
+		p.err = errors.New("Missing --reduce after --init")
+		return nil
+	}
 	tasks = append(tasks, p.tasks...)
 	if task := p.inputTask(); task != nil {
 		tasks = append(tasks, task)
 	} else if len(tasks) == 0 {
-		tasks = append(tasks, ReadFromTask(p.stdin, YAML))
+		tasks = append(tasks, p.stdinTask())
+	}
+	if p.editFiles {
+		return []StreamTask{p.inPlaceTask()}
+	}
+	out := p.outputTask()
+	if p.err != nil {
+		return nil
+	}
+	if log := p.errorLog(); log != nil {
+		p.eval.Errors = log
+		p.jq.Errors = log
+		for _, task := range p.transforms {
+			if sort, ok := task.(*Sort); ok {
+				sort.Errors = log
+			}
+		}
+		out = p.checkErrors(out)
 	}
-	tasks = append(tasks, p.outputTask())
+	tasks = append(tasks, out)
 	return tasks
 }
 
+// errorLog sets up the error log in keep going mode
+func (p *argParser) errorLog() *ErrorLog {
+	if p.errors == nil && (p.keepGoing || p.errorsPath != "") {
+		if p.errorsPath != "" {
+			f, err := os.Create(p.errorsPath)
+			if err != nil {
+				p.err = err
+				return nil
+			}
+			p.errorsTo = f
+			p.errors = NewErrorLog(p.errorsTo, true)
+		} else {
+			p.errors = NewErrorLog(os.Stderr, false)
+		}
+	}
+	return p.errors
+}
+
+// inPlaceTask edits input files in place running all tasks on the values of each file.
+// In keep going mode files with errors are skipped as a whole.
+func (p *argParser) inPlaceTask() StreamTask {
+	ip := &p.inPlace
+	if len(ip.Files) == 0 {
+		p.err = errors.New("No files to edit in place")
+		return nil
+	}
+	for _, f := range ip.Files {
+		switch f.Path {
+		case "", "-":
+			p.err = errors.New("Cannot edit stdin in place")
+			return nil
+		}
+	}
+	files, err := p.inPlaceFiles()
+	if err != nil {
+		return StreamFunc(func(Stream) error {
+			return err
+		})
+	}
+	ip.Files = files
+	ip.Tasks = p.transforms
+	ip.Stdout = p.stdout
+	ip.Errors = p.errorLog()
+	ip.CSV = p.csv
+	if ip.Errors == nil {
+		return ip
+	}
+	return StreamFunc(func(s Stream) error {
+		if p.errorsTo != nil {
+			defer p.errorsTo.Close()
+		}
+		if err := ip.Run(s); err != nil {
+			return err
+		}
+		return p.errors.Err()
+	})
+}
+
 // Usage for ycat cmd
 const Usage = `
 ycat - command line YAML/JSON processor
@@ -90,15 +203,53 @@ USAGE:
     ycat [OPTIONS] [PIPELINE...]
 
 OPTIONS:
-    -o, --out {json|j|yaml|y}    Set output format
+    -o, --out {json|j|json-pretty|jp|yaml|y|toml|t|csv|tsv|raw|r|raw0}
+                                 Set output format
+    -r, --raw                    Write strings unquoted one per line, other values as JSON
+        --raw0                   Same as above with values separated by NUL
+        --output-dir <DIR>       Write each value to a file in DIR (default current directory)
+        --output-name <SNIPPET>  Jsonnet snippet for the file name of each value
+        --append                 Write values with the same file name to a multi-document file
+    -I, --in-place               Edit input files in place keeping their format
+        --backup <SUFFIX>        Keep a copy of edited files with SUFFIX appended to the name
+        --check                  List files that would change without editing them, exit 1 if any
+        --keep-going             Skip documents that fail to decode or evaluate and continue
+        --errors-to <FILE>       Log errors of failed documents to FILE as NDJSON, implies --keep-going
     -h, --help                   Show help and exit
 
+JSON OUTPUT:
+        --indent <N>             Indent JSON output with N spaces (json-pretty default 2)
+        --tab                    Indent JSON output with tabs
+        --sort-keys              Sort object keys in JSON output
+        --ascii                  Escape non-ASCII characters in JSON output
+
 INPUT:
-    [FILE...]                    Read values from file(s)
+    [FILE...]                    Read values from file(s), directories and quoted glob patterns (dir/**/*.yaml)
+                                 are searched recursively for data files
+        --include <PATTERN>      Read only files in directories matching a glob pattern
+        --exclude <PATTERN>      Skip files and directories matching a glob pattern
+        --follow-symlinks        Follow symbolic links in directories
     -y, --yaml [FILE...]         Read YAML values from file(s)
     -j, --json [FILE...]         Read JSON values from file(s)
+    -t, --toml [FILE...]         Read TOML values from file(s)
+        --csv [FILE...]          Read CSV rows from file(s)
+        --tsv [FILE...]          Read TSV rows from file(s)
+    -l, --lines [FILE...]        Read lines of text from file(s) as strings
+        --keep-newlines          Keep newlines at the end of text lines
+        --raw-input              Read text input as a single string
+        --null-data              Read NUL separated text input instead of lines
+        --base64 [FILE...]       Read file(s) as base64 encoded strings
+        --hex [FILE...]          Read file(s) as hex encoded strings
+        --wrap                   Read base64/hex files as {path, size, data} objects
+        --no-header              Read CSV/TSV rows as arrays and write no header
+        --infer-types            Convert numbers and booleans in CSV/TSV cells
     -n, --null                   Inject a null value 
     -a, --array                  Merge values to array
+        --each, --spread         Split array values to separate values
+        --drop-null              Remove null values
+        --sort-by <EXPR>         Sort values by a JSONPath (.a.b[0]) or Jsonnet expression, repeat for more keys
+                                 Use @k8s to sort Kubernetes manifests in install order
+        --reverse                Reverse the order of the last --sort-by
 
 PIPELINE:
     [INPUT...] [ENV...] EVAL
@@ -106,14 +257,34 @@ PIPELINE:
 ENV:
     -v, --var <VAR>=<CODE>       Bind Jsonnet variable to code
               <VAR>==<VALUE>     Bind Jsonnet variable to a string value
+        --var-file <VAR>=<FILE>  Bind Jsonnet variable to the contents of a file as a string
+        --var-json <VAR>=<FILE>  Bind Jsonnet variable to the values of a JSON/YAML/TOML/CSV file
+        --env <VAR>              Bind Jsonnet variable to the value of an environment variable
+        --env-all                Bind all environment variables to an env object
     -i, --import <VAR>=<FILE>    Import file into a local Jsonnet variable
+    -A, --tla-str <VAR>[=<VALUE>]
+                                 Bind top-level argument to a string value (default from env var VAR)
+        --tla-code <VAR>[=<CODE>]
+                                 Bind top-level argument to code (default from env var VAR)
+        --tla-file <VAR>=<FILE>  Bind top-level argument to an imported file
+    -J, --jpath <DIR>            Add a Jsonnet library search path, right-most wins
         --input-var <VAR>        Change the name of the input value variable (default x) 
+        --meta-var <VAR>         Bind the metadata of the input value to a variable (also available as _.meta)
         --max-stack <SIZE>       Jsonnet VM max stack size (default 500)
+        --jobs <N>               Evaluate values using N parallel Jsonnet VMs (0 for number of CPUs)
+        --dump-errors <SIZE>     Include up to SIZE bytes of the input value in evaluation errors
 
 EVAL:
     <SCRIPT>                     Evaluate a Jsonnet script for each value.
     -x, --exec <SCRIPT>          Same as above regardless of file extension.
     -e, --eval <SNIPPET>         Evaluate a Jsonnet snippet for each value.
+        --reduce <SNIPPET>       Fold all values to a single value, the previous result is bound to acc
+        --init <CODE>            Initial value of acc for the next --reduce (default null)
+        --slurp <SNIPPET>        Evaluate a snippet once with all values as an array, array results are split
+    -q, --jq <FILTER>            Run a jq filter on each value.
+        --arg <NAME>=<VALUE>     Bind jq variable $NAME to a string value
+        --argjson <NAME>=<JSON>  Bind jq variable $NAME to a JSON value
+        --args [VALUE...]        Add string values to jq $ARGS.positional
 
 
 If no INPUT is specified, values are read from stdin as YAML.
@@ -121,24 +292,34 @@ If FILE is "-" or "" values are read from stdin until EOF.
 If FILE has no type option, format is detected from extension:
     .json         -> JSON
     .yaml, .yml   -> YAML
+    .toml         -> TOML
+    .csv, .tsv    -> CSV/TSV with header
     .jsonnet      -> Jsonnet script
+    .jq           -> jq filter
     .*            -> YCAT_FORMAT environment variable or YAML
 
-Default output format is YAML unless YCAT_OUTPUT environment variable is 'json'
+Default output format is YAML unless YCAT_OUTPUT environment variable is 'json' or 'toml'
 
 `
 
 var shortArgs = map[byte]string{
 	'j': "json",
 	'y': "yaml",
+	't': "toml",
 	'i': "import",
 	'v': "var",
 	'e': "eval",
 	'x': "exec",
+	'q': "jq",
 	'o': "out",
 	'n': "null",
 	'a': "array",
 	'h': "help",
+	'r': "raw",
+	'I': "in-place",
+	'J': "jpath",
+	'A': "tla-str",
+	'l': "lines",
 }
 
 func (p *argParser) parseLong(name, value string, argv []string) ([]string, error) {
@@ -150,9 +331,39 @@ func (p *argParser) parseLong(name, value string, argv []string) ([]string, erro
 			return argv, fmt.Errorf("Invalid max stack size: %s", err)
 		}
 		p.Eval().MaxStackSize = size
+	case "jobs":
+		value, argv = shiftArgV(value, argv)
+		jobs, err := strconv.Atoi(value)
+		if err != nil {
+			return argv, fmt.Errorf("Invalid number of jobs: %s", err)
+		}
+		if jobs <= 0 {
+			jobs = runtime.NumCPU()
+		}
+		p.Eval().Jobs = jobs
+	case "dump-errors":
+		value, argv = shiftArgV(value, argv)
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			return argv, fmt.Errorf("Invalid dump size: %s", err)
+		}
+		p.Eval().DumpSize = size
+		p.jq.DumpSize = size
+	case "jpath":
+		value, argv = shiftArgV(value, argv)
+		if value == "" {
+			return argv, errors.New("Missing library path")
+		}
+		p.eval.JPath = append(p.eval.JPath, value)
 	case "input-var":
 		value, argv = shiftArgV(value, argv)
 		p.Eval().Bind = value
+	case "meta-var":
+		value, argv = shiftArgV(value, argv)
+		if value == "" {
+			return argv, errors.New("Missing metadata variable name")
+		}
+		p.eval.MetaVar = value
 	case "import":
 		value, argv = shiftArgV(value, argv)
 		name, file := splitArgV(value)
@@ -169,6 +380,67 @@ func (p *argParser) parseLong(name, value string, argv []string) ([]string, erro
 			typ = RawVar
 		}
 		p.Eval().AddVar(typ, name, value)
+	case "tla-str", "tla-code", "tla-file":
+		value, argv = shiftArgV(value, argv)
+		arg, v := splitArgV(value)
+		if arg == "" {
+			return argv, fmt.Errorf("Missing top-level argument name for --%s", name)
+		}
+		if !strings.Contains(value, "=") {
+			// Same as jsonnet, read the value from env
+			v = os.Getenv(arg)
+		}
+		switch name {
+		case "tla-str":
+			p.eval.AddTLA(RawVar, arg, v)
+		case "tla-code":
+			p.eval.AddTLA(CodeVar, arg, v)
+		default:
+			p.eval.AddTLA(FileVar, arg, v)
+		}
+	case "var-file", "var-json":
+		value, argv = shiftArgV(value, argv)
+		arg, path := splitArgV(value)
+		if arg == "" || path == "" {
+			return argv, fmt.Errorf("Invalid --%s argument: %q", name, value)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return argv, err
+		}
+		if name == "var-file" {
+			p.eval.AddVar(RawVar, arg, string(data))
+			break
+		}
+		format := DetectFormat(path)
+		switch format {
+		case JSONNET, JQ:
+			return argv, fmt.Errorf("Invalid data file for --%s: %q", name, path)
+		}
+		v, err := importValue(string(data), path, format)
+		if err != nil {
+			return argv, err
+		}
+		p.eval.AddVar(CodeVar, arg, string(v))
+	case "env":
+		value, argv = shiftArgV(value, argv)
+		env, ok := os.LookupEnv(value)
+		if !ok {
+			return argv, fmt.Errorf("Environment variable %q is not set", value)
+		}
+		p.eval.AddVar(RawVar, value, env)
+	case "env-all":
+		env := make(map[string]string)
+		for _, kv := range os.Environ() {
+			if k, v := splitArgV(kv); k != "" {
+				env[k] = v
+			}
+		}
+		data, err := json.Marshal(env)
+		if err != nil {
+			return argv, err
+		}
+		p.eval.AddVar(CodeVar, "env", string(data))
 	case "eval":
 		value, argv = shiftArgV(value, argv)
 		filename, err := EvalFilename()
@@ -176,6 +448,51 @@ func (p *argParser) parseLong(name, value string, argv []string) ([]string, erro
 			return argv, err
 		}
 		p.addTask(p.eval.Snippet(filename, value))
+	case "init":
+		value, argv = shiftArgV(value, argv)
+		p.init = value
+		p.initPending = true
+	case "reduce":
+		value, argv = shiftArgV(value, argv)
+		filename, err := EvalFilename()
+		if err != nil {
+			return argv, err
+		}
+		p.addTask(p.eval.Reduce(filename, value, p.init))
+		p.initPending = false
+	case "slurp":
+		value, argv = shiftArgV(value, argv)
+		filename, err := EvalFilename()
+		if err != nil {
+			return argv, err
+		}
+		p.addTask(p.eval.Slurp(filename, value))
+	case "jq":
+		value, argv = shiftArgV(value, argv)
+		task, err := p.jq.FilterDeferred(value)
+		if err != nil {
+			return argv, fmt.Errorf("Invalid jq filter: %s", err)
+		}
+		p.addTask(task)
+	case "arg":
+		value, argv = shiftArgV(value, argv)
+		name, value := splitArgV(value)
+		p.jq.AddArg(name, value)
+	case "argjson":
+		value, argv = shiftArgV(value, argv)
+		name, value := splitArgV(value)
+		x, err := jqValue(RawValue(value))
+		if err != nil {
+			return argv, fmt.Errorf("Invalid JSON value for %q: %s", name, err)
+		}
+		p.jq.AddArg(name, x)
+	case "args":
+		if len(value) > 0 && value[0] == '=' {
+			p.jq.AddPositional(value[1:])
+		}
+		for ; len(argv) > 0 && !isOption(argv[0]); argv = argv[1:] {
+			p.jq.AddPositional(argv[0])
+		}
 	case "exec":
 		value, argv = shiftArgV(value, argv)
 		p.addFile(value, JSONNET)
@@ -188,14 +505,114 @@ func (p *argParser) parseLong(name, value string, argv []string) ([]string, erro
 		p.input = append(p.input, NullStream{})
 	case "to-json":
 		p.output = OutputJSON
+	case "raw":
+		p.output = OutputRaw
+	case "raw0":
+		p.output = OutputRaw0
+	case "indent":
+		value, argv = shiftArgV(value, argv)
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 0 {
+			return argv, fmt.Errorf("Invalid indent size: %q", value)
+		}
+		p.json.Indent = size
+	case "tab":
+		p.json.Tab = true
+	case "sort-keys":
+		p.json.SortKeys = true
+	case "ascii":
+		p.json.ASCII = true
 	case "help":
 		p.help = true
 	case "array":
 		p.addTask(ToArray{})
+	case "each", "spread":
+		p.addTask(Spread{})
+	case "drop-null":
+		p.addTask(DropNull{})
+	case "sort-by":
+		value, argv = shiftArgV(value, argv)
+		key, err := NewSortKey(value, &p.eval)
+		if err != nil {
+			return argv, err
+		}
+		if n := len(p.tasks); n > 0 && p.sort != nil && p.tasks[n-1] == StreamTask(p.sort) && p.input == nil {
+			p.sort.Keys = append(p.sort.Keys, SortOrder{Key: key})
+			break
+		}
+		p.sort = &Sort{Keys: []SortOrder{{Key: key}}}
+		p.addTask(p.sort)
+	case "reverse":
+		if p.sort == nil {
+			return argv, errors.New("Option --reverse requires --sort-by")
+		}
+		p.sort.Keys[len(p.sort.Keys)-1].Reverse = true
 	case "yaml":
 		return p.parseFiles(value, argv, YAML), nil
 	case "json":
 		return p.parseFiles(value, argv, JSON), nil
+	case "toml":
+		return p.parseFiles(value, argv, TOML), nil
+	case "csv":
+		return p.parseFiles(value, argv, CSV), nil
+	case "tsv":
+		return p.parseFiles(value, argv, TSV), nil
+	case "base64":
+		return p.parseFiles(value, argv, BASE64), nil
+	case "hex":
+		return p.parseFiles(value, argv, HEX), nil
+	case "wrap":
+		p.wrap = true
+	case "lines":
+		return p.parseFiles(value, argv, TEXT), nil
+	case "keep-newlines":
+		p.text.KeepNewlines = true
+	case "raw-input":
+		p.text.Raw = true
+	case "null-data":
+		p.text.NullData = true
+	case "no-header":
+		p.csv.NoHeader = true
+	case "infer-types":
+		p.csv.InferTypes = true
+	case "include":
+		value, argv = shiftArgV(value, argv)
+		p.walk.Include = append(p.walk.Include, value)
+	case "exclude":
+		value, argv = shiftArgV(value, argv)
+		p.walk.Exclude = append(p.walk.Exclude, value)
+	case "follow-symlinks":
+		p.walk.FollowSymlinks = true
+	case "keep-going":
+		p.keepGoing = true
+	case "errors-to":
+		value, argv = shiftArgV(value, argv)
+		if value == "" {
+			return argv, errors.New("Missing errors file")
+		}
+		p.errorsPath = value
+	case "output-dir":
+		value, argv = shiftArgV(value, argv)
+		if value == "" {
+			return argv, errors.New("Missing output directory")
+		}
+		p.split.Dir = value
+	case "output-name":
+		value, argv = shiftArgV(value, argv)
+		if value == "" {
+			return argv, errors.New("Missing output name expression")
+		}
+		p.split.Name = value
+	case "append":
+		p.split.Append = true
+	case "in-place":
+		p.editFiles = true
+	case "backup":
+		value, argv = shiftArgV(value, argv)
+		p.inPlace.Backup = value
+	case "check":
+		p.editFiles = true
+		p.inPlace.Check = true
 	case "debug":
 		value, argv = shiftArgV(value, argv)
 		if value == "" {
@@ -221,9 +638,10 @@ func (p *argParser) Eval() *Eval {
 }
 
 func (p *argParser) addTask(t StreamTask) {
+	p.transforms = append(p.transforms, t)
 	if input := p.inputTask(); input == nil {
 		if len(p.tasks) == 0 {
-			input = ReadFromTask(p.stdin, YAML)
+			input = p.stdinTask()
 			p.tasks = append(p.tasks, input, t)
 		} else {
 			p.tasks = append(p.tasks, t)
@@ -252,7 +670,8 @@ func (p *argParser) parseFiles(path string, argv []string, format Format) []stri
 			path = path[1:]
 		}
 		p.addFile(path, format)
-	case len(argv) == 0:
+	case len(argv) == 0 || isOption(argv[0]):
+		// No files, read from stdin
 		p.addFile("", format)
 	default:
 		for ; len(argv) > 0 && !isOption(argv[0]); argv = argv[1:] {
@@ -267,32 +686,152 @@ func isOption(a string) bool {
 }
 
 func (p *argParser) addFile(path string, format Format) {
-	if format == Auto {
+	switch {
+	case format == JSONNET || format == JQ:
+		// Scripts are never searched
+	case isWalkPath(path):
+		p.addDir(path, format)
+		return
+	case format == Auto:
 		format = DetectFormat(path)
 	}
-	if format == JSONNET {
+	switch format {
+	case JSONNET:
 		p.addTask(p.eval.SnippetFromFile(path))
 		return
+	case JQ:
+		p.addTask(p.jq.FilterFromFile(path))
+		return
+	case BASE64, HEX:
+	default:
+		p.inPlace.Files = append(p.inPlace.Files, InPlaceFile{path, format})
 	}
+	p.input = append(p.input, p.skipErrors(p.readFile(path, format)))
+}
+
+// addDir adds the files in a directory or matching a glob pattern.
+// Files are listed when the pipeline runs so that walk options can follow the path.
+func (p *argParser) addDir(path string, format Format) {
+	switch format {
+	case BASE64, HEX:
+	default:
+		p.inPlace.Files = append(p.inPlace.Files, InPlaceFile{path, format})
+	}
+	p.input = append(p.input, p.skipErrors(ProducerFunc(func(s WriteStream) error {
+		files, err := p.walk.Files(path, format)
+		if err != nil {
+			return err
+		}
+		for _, filename := range files {
+			format := format
+			if format == Auto {
+				format = DetectFormat(filename)
+			}
+			if err := p.skipErrors(p.readFile(filename, format))(s); err != nil {
+				return err
+			}
+		}
+		return nil
+	})))
+}
+
+// readFile creates a Producer reading values from a file
+func (p *argParser) readFile(path string, format Format) Producer {
+	switch format {
+	case BASE64, HEX:
+		return ProducerFunc(func(s WriteStream) error {
+			switch path {
+			case "", "-":
+				return ReadBinary(p.stdin, "-", format, p.wrap)(s)
+			default:
+				return ReadBinaryFile(path, format, p.wrap)(s)
+			}
+		})
+	}
+	dec := p.decoder(format)
 	switch path {
 	case "", "-":
 		// Handle here to be able to test stdin
-		p.input = append(p.input, ReadFromTask(p.stdin, format))
+		return WithFormat(ReadFromTaskWith(p.stdin, dec), format)
 	default:
-		p.input = append(p.input, ReadFromFile(path, format))
+		return WithFormat(ReadFromFileWith(path, dec), format)
+	}
+}
+
+// inPlaceFiles lists the files to edit in place expanding directories
+func (p *argParser) inPlaceFiles() ([]InPlaceFile, error) {
+	var files []InPlaceFile
+	for _, f := range p.inPlace.Files {
+		if !isWalkPath(f.Path) {
+			files = append(files, f)
+			continue
+		}
+		paths, err := p.walk.Files(f.Path, f.Format)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			files = append(files, InPlaceFile{path, f.Format})
+		}
 	}
+	return files, nil
 }
 
-func (p *argParser) outputTask() (s StreamTask) {
+// stdinTask reads YAML values from stdin when there is no input
+func (p *argParser) stdinTask() StreamTask {
+	return p.skipErrors(p.readFile("-", YAML))
+}
+
+// skipErrors reports input errors to the error log in keep going mode
+func (p *argParser) skipErrors(task Producer) ProducerFunc {
+	return func(s WriteStream) error {
+		return KeepGoing(task, p.errors).Produce(s)
+	}
+}
+
+// checkErrors fails the output task if any documents failed in keep going mode
+func (p *argParser) checkErrors(out ConsumerFunc) ConsumerFunc {
+	return func(s ReadStream) error {
+		err := out(s)
+		if p.errorsTo != nil {
+			p.errorsTo.Close()
+		}
+		if err != nil {
+			return err
+		}
+		return p.errors.Err()
+	}
+}
+
+// decoder returns a DecoderFunc for a format applying format options
+func (p *argParser) decoder(format Format) DecoderFunc {
+	switch format {
+	case CSV, TSV:
+		return p.csv.Decoder(format)
+	case TEXT:
+		return p.text.Decoder()
+	default:
+		return FormatDecoder(format)
+	}
+}
+
+func (p *argParser) outputTask() ConsumerFunc {
 	if p.output == OutputInvalid {
 		p.output = DefaultOutput()
 	}
-	switch p.output {
-	case OutputJSON:
-		return StreamWriteJSON(p.stdout)
-	default:
-		return StreamWriteYAML(p.stdout)
+	if p.split.Name == "" && (p.split.Dir != "" || p.split.Append) {
+		p.err = errors.New("Missing --output-name for --output-dir or --append")
+		return nil
+	}
+	if p.split.Dir != "" || p.split.Name != "" {
+		split := &p.split
+		split.Output = p.output
+		split.JSON = p.json
+		split.CSV = &p.csv
+		split.Eval = &p.eval
+		return split.Consume
 	}
+	return StreamWrite(p.stdout, p.output, p.json, &p.csv)
 }
 
 func (p *argParser) inputTask() (s StreamTask) {
@@ -36,7 +36,84 @@ func TestParseArgs(t *testing.T) {
 		{[]string{"testdata/foo.yaml", "-o", "j"}, "", `{"foo":"bar"}` + "\n"},
 		{[]string{"testdata/foo.yaml", "testdata/bar.json"}, "", "foo: bar\n---\nbar: foo\n"},
 		{[]string{"testdata/foo.yaml", "testdata/bar.json", "-a"}, "", "- foo: bar\n- bar: foo\n"},
-		{[]string{"testdata/foo.yaml", "-e", `{bar: "baz"} + x`}, "", "bar: baz\nfoo: bar\n"},
+		{[]string{"testdata/foo.yaml", "-e", `{bar: "baz"} + x`}, "", "foo: bar\nbar: baz\n"},
+		{[]string{"-e", `x + {a: 0, metadata+: {namespace: "foo"}}`}, "kind: Foo\nmetadata:\n  name: foo\n  labels: {}\n", "kind: Foo\nmetadata:\n  name: foo\n  labels: {}\n  namespace: foo\na: 0\n"},
+		{nil, "# head\nfoo: bar # line\n# foot\n", "# head\nfoo: bar # line\n# foot\n"},
+		{[]string{"-e", `x + {bar: x.foo}`}, "a: 1\n# foo\nfoo:\n  - 1 # one\n  - 2\n", "a: 1\n# foo\nfoo:\n  - 1 # one\n  - 2\nbar:\n  - 1\n  - 2\n"},
+		{[]string{"testdata/foo.json", "-q", ".foo"}, "", "bar\n"},
+		{[]string{"testdata/bar.json", "testdata/bar.jq"}, "", "bar: foofoo\n"},
+		{[]string{"testdata/foo.json", "testdata/filter.jq", "--args", "a", "b"}, "", "foo: bar\nargs:\n  - a\n  - b\n"},
+		{[]string{"-q", ".[]"}, "[1, 2]", "1\n---\n2\n"},
+		{[]string{"-q", "empty"}, "foo", ""},
+		{[]string{"-n", "--arg", "n=v", "--argjson", "m={\"a\": 1}", "-q", "[$n, $m, $ARGS.named.n]", "-o", "j"}, "", `["v",{"a":1},"v"]` + "\n"},
+		{[]string{"-q", ". + {a: 1}"}, "z: 0\nb: 1\n", "z: 0\nb: 1\na: 1\n"},
+		{[]string{"-t", "-o", "j"}, "b = 1\na = [\"x\"]\n[t]\nc = true\n", `{"b":1,"a":["x"],"t":{"c":true}}` + "\n"},
+		{[]string{"-o", "t"}, "b: 1\na: {c: [1, 2]}\nd: [{e: x}]\n", "b = 1\n\n[a]\nc = [1, 2]\n\n[[d]]\ne = \"x\"\n"},
+		{[]string{"-j", "-e", "x + 1"}, "1", "2\n"},
+		{[]string{"--csv", "-o", "j"}, "a,b\n1,x\n2,\"y,z\"\n", `{"a":"1","b":"x"}` + "\n" + `{"a":"2","b":"y,z"}` + "\n"},
+		{[]string{"--infer-types", "--tsv", "-o", "j"}, "a\tb\n1.5\ttrue\n", `{"a":1.5,"b":true}` + "\n"},
+		{[]string{"--no-header", "--csv", "-o", "j"}, "a,b\n", `["a","b"]` + "\n"},
+		{[]string{"-o", "csv"}, "b: 1\na: {c: x}\n---\nd: [1]\nb: 2\n", "b,a.c,d\n1,x,\n2,,[1]\n"},
+		{[]string{"-o", "tsv", "--no-header"}, "[1, \"a b\"]", "1\ta b\n"},
+		{[]string{"-o", "jp"}, "b: 1\na: [1]\n", "{\n  \"b\": 1,\n  \"a\": [\n    1\n  ]\n}\n"},
+		{[]string{"-o", "j", "--tab"}, "a: 1", "{\n\t\"a\": 1\n}\n"},
+		{[]string{"-o", "j", "--sort-keys"}, "b: 1\na: {d: 1, c: 2}\n", `{"a":{"c":2,"d":1},"b":1}` + "\n"},
+		{[]string{"-o", "j", "--ascii"}, "a: ä😀<", `{"a":"\u00e4\ud83d\ude00<"}` + "\n"},
+		{[]string{"-r"}, "foo\n---\n\"a\\nb\"\n---\n[1, \"x\"]\n---\n{a: 1}\n", "foo\na\nb\n[1,\"x\"]\n{\"a\":1}\n"},
+		{[]string{"--raw0", "-e", "x.name"}, "name: foo\n---\nname: bar\n", "foo\x00bar\x00"},
+		{[]string{"-o", "raw"}, "null", "null\n"},
+		{[]string{"--init", "{n: 0}", "--reduce", "{n: acc.n + x}"}, "1\n---\n2\n---\n3\n", "n: 6\n"},
+		{[]string{"-n", "--init", "[]", "--reduce", "acc + [x]"}, "", "- null\n"},
+		{[]string{"-j", "--reduce", "x"}, "", "null\n"},
+		{[]string{"--reduce", "x"}, "b: 1\na: 2\n", "b: 1\na: 2\n"},
+		{[]string{"--init", "{}", "--reduce", "acc + x"}, "b: 1\na: 2\n---\nc: 3\na: 4\n", "b: 1\na: 4\nc: 3\n"},
+		{[]string{"--slurp", "std.sort(x, function(v) v.b)"}, "b: 2\na: 1\n---\nb: 1\na: 2\n", "b: 1\na: 2\n---\nb: 2\na: 1\n"},
+		{[]string{"--slurp", "std.length(x)"}, "1\n---\n2\n", "2\n"},
+		{[]string{"-e", "[x, {b: x.a}]", "--each"}, "a: 1\n---\na: 2\n", "a: 1\n---\nb: 1\n---\na: 2\n---\nb: 2\n"},
+		{[]string{"--spread", "-o", "j"}, "[1, [2]]\n---\n3\n---\n[]\n", "1\n[2]\n3\n"},
+		{[]string{"-e", "if x.a > 1 then x", "--drop-null"}, "a: 1\n---\na: 2\n", "a: 2\n"},
+		{[]string{"-a", "--each"}, "# foo\na: 1\n---\na: 2\n", "a: 1\n---\na: 2\n"},
+		{[]string{"-n", "-J", "testdata/lib", "-e", `(import "greet.libsonnet").greet("foo")`}, "", "hello foo\n"},
+		{[]string{"-n", "-J", "testdata/lib", "-J", "testdata/vendor", "-e", `(import "greet.libsonnet").greet("foo")`}, "", "hi foo\n"},
+		{[]string{"-n", "--jpath=testdata/lib", "-i", "lib=greet.libsonnet", "-e", `lib.greet("foo")`}, "", "hello foo\n"},
+		{[]string{"-n", "-e", `import "testdata/foo.yaml"`}, "", "foo: bar\n"},
+		{[]string{"-n", "-e", `import "testdata/multi.yaml"`, "-o", "j"}, "", `[{"a":1},{"a":2}]` + "\n"},
+		{[]string{"-n", "-e", `(import "testdata/foo.toml").b`, "-o", "j"}, "", `{"c":"d"}` + "\n"},
+		{[]string{"-n", "-e", `[r.name for r in import "testdata/foo.csv"]`, "-o", "j"}, "", `["foo","bar"]` + "\n"},
+		{[]string{"-n", "-i", "foo=testdata/foo.yaml", "-e", `foo`, "-o", "j"}, "", `{"foo":"bar"}` + "\n"},
+		{[]string{"--tla-code", "n=2", "testdata/tla.jsonnet"}, "a: 1\n", "a: 1\nn: 2\n"},
+		{[]string{"testdata/tla.jsonnet"}, "a: 1\n", "a: 1\nn: 1\n"},
+		{[]string{"-A", "n=foo", "-e", "function(n) n"}, "a: 1\n", "foo\n"},
+		{[]string{"--tla-file", "n=testdata/foo.yaml", "--input-var", "y", "-e", "function(y, n) [y.a, n.foo]", "-o", "j"}, "a: 1\n", `[1,"bar"]` + "\n"},
+		{[]string{"--slurp", "function(x) std.length(x)"}, "1\n---\n2\n", "2\n"},
+		{[]string{"--jobs", "2", "-e", "function(x) x * 2"}, "1\n---\n2\n", "2\n---\n4\n"},
+		{[]string{"-n", "--var-file", "foo=testdata/foo.yaml", "-e", "foo"}, "", "|\n  foo: bar\n"},
+		{[]string{"-n", "--var-json", "foo=testdata/foo.yaml", "--var-json", "bar=testdata/bar.json", "-e", "[foo, bar]", "-o", "j"}, "", `[{"foo":"bar"},{"bar":"foo"}]` + "\n"},
+		{[]string{"-n", "--var-json=rows=testdata/foo.csv", "-e", "rows[1].name"}, "", "bar\n"},
+		{[]string{"-l", "-o", "j"}, "foo\r\n<bar>\n\nbaz", `"foo"` + "\n" + `"<bar>"` + "\n" + `""` + "\n" + `"baz"` + "\n"},
+		{[]string{"--keep-newlines", "-l", "-o", "j"}, "foo\nbar", `"foo\n"` + "\n" + `"bar"` + "\n"},
+		{[]string{"--lines", "--raw-input", "-o", "j"}, "foo\nbar\n", `"foo\nbar\n"` + "\n"},
+		{[]string{"--lines", "--null-data", "-e", "{name: x}"}, "a b\x00c\x00", "name: a b\n---\nname: c\n"},
+		{[]string{"-l"}, "", ""},
+		{[]string{"testdata/foo.txt", "-r"}, "", "foo\nbar\n"},
+		{[]string{"--base64", "testdata/foo.txt", "--hex"}, "\x00\xff", "Zm9vCmJhcgo=\n---\n00ff\n"},
+		{[]string{"--wrap", "--base64", "testdata/foo.txt", "-o", "j"}, "", `{"path":"testdata/foo.txt","size":8,"data":"Zm9vCmJhcgo="}` + "\n"},
+		{[]string{"--sort-by", ".a", "-o", "j"}, "a: 2\n---\na: x\n---\nb: 1\n---\na: 1\n", `{"b":1}` + "\n" + `{"a":1}` + "\n" + `{"a":2}` + "\n" + `{"a":"x"}` + "\n"},
+		{[]string{"--sort-by", "$.a[0]", "--reverse", "-o", "j"}, "a: [1]\n---\na: [3]\n---\na: [2]\n", `{"a":[3]}` + "\n" + `{"a":[2]}` + "\n" + `{"a":[1]}` + "\n"},
+		{[]string{"--sort-by", ".a", "--sort-by=-x.b", "-o", "j"}, "{a: 1, b: 1}\n---\n{a: 0, b: 1}\n---\n{a: 1, b: 2}\n", `{"a":0,"b":1}` + "\n" + `{"a":1,"b":2}` + "\n" + `{"a":1,"b":1}` + "\n"},
+		{[]string{"-j", "--sort-by", "$", "-o", "j"}, `{"b":1} {"a":2} {"a":1,"c":0} {"a":1,"b":5} {"b":0,"a":1}`, `{"a":2}` + "\n" + `{"b":0,"a":1}` + "\n" + `{"a":1,"b":5}` + "\n" + `{"a":1,"c":0}` + "\n" + `{"b":1}` + "\n"},
+		{[]string{"--sort-by", "std.length(x)", "-o", "j"}, "abc\n---\nb\n---\na\n", `"b"` + "\n" + `"a"` + "\n" + `"abc"` + "\n"},
+		{[]string{"--sort-by", "@k8s", "-e", "x.kind"}, "kind: Deployment\n---\nkind: Foo\n---\nkind: Namespace\n---\nkind: ClusterRole\n", "Namespace\n---\nClusterRole\n---\nDeployment\n---\nFoo\n"},
+		{[]string{"-e", "[_.meta.document, _.meta.line, _.meta.format]", "-o", "j"}, "a: 1\n---\n\nb: 2\n", `[1,1,"yaml"]` + "\n" + `[2,4,"yaml"]` + "\n"},
+		{[]string{"testdata/foo.yaml", "--meta-var", "m", "-e", "x + {file: m.file}"}, "", "foo: bar\nfile: testdata/foo.yaml\n"},
+		{[]string{"-n", "-e", "_.meta.file"}, "", "null\n"},
+		{[]string{"--sort-by=-_.meta.document", "-o", "j"}, "a\n---\nb\n---\nc\n", `"c"` + "\n" + `"b"` + "\n" + `"a"` + "\n"},
+		{[]string{"-n", "-q", "[$a, $ARGS.positional]", "--arg", "a=b", "-o", "j", "--args", "c"}, "", `["b",["c"]]` + "\n"},
+		{[]string{"-j", "--slurp", "[x[1], x[0]]", "-o", "j"}, `{"b":1,"a":2}` + "\n" + `{"a":1,"b":2}` + "\n", `{"a":1,"b":2}` + "\n" + `{"b":1,"a":2}` + "\n"},
+		{[]string{"-n", "-e", `importstr "raw:testdata/foo.yaml"`, "-o", "j"}, "", `"foo: bar\n"` + "\n"},
+		{[]string{"-n", "-e", `[importstr "raw:testdata/foo.yaml", (import "testdata/foo.yaml").foo]`, "-o", "j"}, "", `["foo: bar\n","bar"]` + "\n"},
+		{[]string{"--sort-by", ".a", "--sort-by", ".b", "--reverse", "-o", "j"}, "{a: 1, b: 1}\n---\n{a: 0, b: 1}\n---\n{a: 1, b: 2}\n", `{"a":0,"b":1}` + "\n" + `{"a":1,"b":2}` + "\n" + `{"a":1,"b":1}` + "\n"},
+		{[]string{"--sort-by", ".a", "--reverse", "--sort-by", ".b", "-o", "j"}, "{a: 1, b: 2}\n---\n{a: 0, b: 1}\n---\n{a: 1, b: 1}\n", `{"a":1,"b":1}` + "\n" + `{"a":1,"b":2}` + "\n" + `{"a":0,"b":1}` + "\n"},
 		// {[]string{""}, false, false, 2, "1", "1\n"},
 	}
 	for i, tc := range tcs {
@@ -62,3 +139,30 @@ func TestParseArgs(t *testing.T) {
 	}
 
 }
+
+func TestParseArgs_Env(t *testing.T) {
+	t.Setenv("YCAT_TEST_FOO", "foo")
+	out, err := runArgs(t, "-n", "--env", "YCAT_TEST_FOO", "--env-all", "-e", "[YCAT_TEST_FOO, env.YCAT_TEST_FOO]", "-o", "j")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `["foo","foo"]` + "\n"; out != want {
+		t.Errorf("Wrong output: %q != %q", out, want)
+	}
+	if _, _, err := ycat.ParseArgs([]string{"--env", "YCAT_TEST_UNSET"}, nil, nil); err == nil {
+		t.Errorf("No error for unset env var")
+	}
+}
+
+func TestParseArgs_Usage(t *testing.T) {
+	for _, args := range [][]string{
+		{"--reduce", "acc + x", "--init", "0"},
+		{"--init", "0"},
+		{"--output-dir", "out"},
+		{"--append"},
+	} {
+		if _, _, err := ycat.ParseArgs(args, &bytes.Buffer{}, nil); err == nil {
+			t.Errorf("%v: No usage error", args)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package ycat
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// ReadBinaryFile creates a StreamTask to read a file as a base64 or hex encoded string.
+// If wrap is set the value is an object with the path, size and data of the file.
+func ReadBinaryFile(path string, format Format, wrap bool) ProducerFunc {
+	return func(s WriteStream) error {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return ReadBinary(f, path, format, wrap)(s)
+	}
+}
+
+// ReadBinary creates a StreamTask to read all data from a Reader as a base64 or hex encoded string
+func ReadBinary(r io.Reader, path string, format Format, wrap bool) ProducerFunc {
+	return func(s WriteStream) error {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		var str string
+		switch format {
+		case BASE64:
+			str = base64.StdEncoding.EncodeToString(data)
+		case HEX:
+			str = hex.EncodeToString(data)
+		default:
+			return fmt.Errorf("Invalid binary format: %d", format)
+		}
+		var x interface{} = str
+		if wrap {
+			x = Map{
+				{Key: "path", Value: path},
+				{Key: "size", Value: json.Number(fmt.Sprint(len(data)))},
+				{Key: "data", Value: str},
+			}
+		}
+		v, err := encodeRawValue(x)
+		if err != nil {
+			return err
+		}
+		PushMeta(s, v, &Meta{Filename: path, Document: 1, Format: format})
+		return nil
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"os"
 
@@ -23,6 +24,16 @@ func printUsage(err error) {
 	usage()
 }
 
+// printError prints decode errors as file:line:col: message
+func printError(err error) {
+	var decodeErr *ycat.DecodeError
+	if errors.As(err, &decodeErr) {
+		os.Stderr.WriteString(decodeErr.Error() + "\n")
+		return
+	}
+	logger.Println(err)
+}
+
 func main() {
 	tasks, help, err := ycat.ParseArgs(os.Args[1:], os.Stdin, os.Stdout)
 	if err != nil {
@@ -38,9 +49,21 @@ func main() {
 	p := ycat.MakePipeline(ctx, tasks...)
 	exitCode := 0
 	for err := range p.Errors() {
-		if err != nil {
+		var (
+			failed  *ycat.FailedError
+			changed *ycat.ChangedError
+		)
+		switch {
+		case err == nil:
+		case errors.As(err, &failed), errors.As(err, &changed):
+			// Some documents failed in keep going mode or files would change in check mode
+			if exitCode == 0 {
+				exitCode = 1
+			}
+			printError(err)
+		default:
 			exitCode = 2
-			logger.Println(err)
+			printError(err)
 		}
 	}
 	os.Exit(exitCode)
@@ -3,12 +3,15 @@ package ycat
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"io"
 	"os"
 	"path"
 	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
 
-	yaml "gopkg.in/yaml.v2"
+	yaml "gopkg.in/yaml.v3"
 )
 
 // Format is input file format
@@ -20,8 +23,36 @@ const (
 	YAML
 	JSON
 	JSONNET
+	JQ
+	TOML
+	CSV
+	TSV
+	TEXT
+	BASE64
+	HEX
 )
 
+var formatNames = [...]string{
+	YAML:    "yaml",
+	JSON:    "json",
+	JSONNET: "jsonnet",
+	JQ:      "jq",
+	TOML:    "toml",
+	CSV:     "csv",
+	TSV:     "tsv",
+	TEXT:    "text",
+	BASE64:  "base64",
+	HEX:     "hex",
+}
+
+// String returns the name of the format, empty for Auto
+func (f Format) String() string {
+	if int(f) < len(formatNames) {
+		return formatNames[f]
+	}
+	return ""
+}
+
 // FormatFromString converts a string to Format
 func FormatFromString(s string) Format {
 	switch strings.ToLower(s) {
@@ -29,6 +60,18 @@ func FormatFromString(s string) Format {
 		return JSON
 	case "yaml", "y":
 		return YAML
+	case "toml", "t":
+		return TOML
+	case "csv":
+		return CSV
+	case "tsv":
+		return TSV
+	case "text", "txt":
+		return TEXT
+	case "base64":
+		return BASE64
+	case "hex":
+		return HEX
 	default:
 		return Auto
 	}
@@ -50,7 +93,7 @@ func DefaultFormat() Format {
 	if defaultFormat == Auto {
 		f := FormatFromString(os.Getenv(EnvDefaultFormat))
 		switch f {
-		case YAML, JSON:
+		case YAML, JSON, TOML:
 			defaultFormat = f
 		default:
 			defaultFormat = YAML
@@ -64,7 +107,7 @@ func DefaultOutput() Output {
 	if defaultOutput == OutputInvalid {
 		f := OutputFromString(os.Getenv(EnvDefaultOutput))
 		switch f {
-		case OutputYAML, OutputJSON:
+		case OutputYAML, OutputJSON, OutputJSONPretty, OutputTOML:
 			defaultOutput = f
 		default:
 			defaultOutput = OutputYAML
@@ -75,15 +118,33 @@ func DefaultOutput() Output {
 
 // DetectFormat detects an input format from the extension
 func DetectFormat(filename string) Format {
+	if format := extFormat(filename); format != Auto {
+		return format
+	}
+	return DefaultFormat()
+}
+
+// extFormat detects a format from a known extension
+func extFormat(filename string) Format {
 	switch path.Ext(filename) {
 	case ".json":
 		return JSON
 	case ".jsonnet":
 		return JSONNET
+	case ".jq":
+		return JQ
 	case ".yaml", ".yml":
 		return YAML
+	case ".toml":
+		return TOML
+	case ".csv":
+		return CSV
+	case ".tsv":
+		return TSV
+	case ".txt":
+		return TEXT
 	default:
-		return DefaultFormat()
+		return Auto
 	}
 }
 
@@ -95,7 +156,12 @@ const (
 	OutputInvalid Output = iota
 	OutputYAML
 	OutputJSON
-	// OutputRaw // Only with --eval
+	OutputJSONPretty
+	OutputTOML
+	OutputCSV
+	OutputTSV
+	OutputRaw
+	OutputRaw0
 )
 
 // OutputFromString converts a string to Output
@@ -103,10 +169,20 @@ func OutputFromString(s string) Output {
 	switch strings.ToLower(s) {
 	case "json", "j":
 		return OutputJSON
+	case "json-pretty", "jp":
+		return OutputJSONPretty
 	case "yaml", "y":
 		return OutputYAML
-	// case "raw", "r":
-	// 	return OutputRaw
+	case "toml", "t":
+		return OutputTOML
+	case "csv":
+		return OutputCSV
+	case "tsv":
+		return OutputTSV
+	case "raw", "r":
+		return OutputRaw
+	case "raw0":
+		return OutputRaw0
 	default:
 		return OutputInvalid
 	}
@@ -122,8 +198,29 @@ func NewDecoder(r io.Reader, format Format) Decoder {
 	switch format {
 	case JSON:
 		return json.NewDecoder(r)
+	case TOML:
+		return &tomlDecoder{r: r}
+	case CSV, TSV:
+		return NewCSVDecoder(r, format, CSVOptions{})
+	case TEXT:
+		return NewTextDecoder(r, TextOptions{})
 	default:
-		return yaml.NewDecoder(r)
+		return newYAMLDecoder(r)
+	}
+}
+
+// lineDecoder is a Decoder that tracks the line where the last value starts
+type lineDecoder interface {
+	Line() int
+}
+
+// DecoderFunc creates a Decoder decoding values from a Reader
+type DecoderFunc func(r io.Reader) Decoder
+
+// FormatDecoder returns a DecoderFunc for a format
+func FormatDecoder(format Format) DecoderFunc {
+	return func(r io.Reader) Decoder {
+		return NewDecoder(r, format)
 	}
 }
 
@@ -132,42 +229,157 @@ func ReadFromFile(path string, format Format) ProducerFunc {
 	if format == Auto {
 		format = DetectFormat(path)
 	}
+	return WithFormat(ReadFromFileWith(path, FormatDecoder(format)), format)
+}
+
+// ReadFromFileWith creates a StreamTask to read values from a file using a custom Decoder
+func ReadFromFileWith(path string, newDecoder DecoderFunc) ProducerFunc {
 	return func(s WriteStream) error {
 		f, err := os.Open(path)
 		if err != nil {
 			return err
 		}
 		defer f.Close()
-		r := ReadFromTask(f, format)
-		return r(s)
+		return readValues(s, f, path, newDecoder)
 	}
 }
 
 // ReadFromTask creates a StreamTask to read values from a Reader
 func ReadFromTask(r io.Reader, format Format) ProducerFunc {
+	return WithFormat(ReadFromTaskWith(r, FormatDecoder(format)), format)
+}
+
+// WithFormat sets the format in the metadata of the values produced by a task
+func WithFormat(task Producer, format Format) ProducerFunc {
 	return func(s WriteStream) error {
-		dec := NewDecoder(r, format)
-		for {
-			var v RawValue
-			if err := dec.Decode(&v); err != nil {
-				if err == io.EOF {
-					return nil
-				}
-				return err
-			}
-			if v == "" {
-				v = "null"
-			}
+		return task.Produce(&formatWriter{s, format})
+	}
+}
+
+// formatWriter sets the format in the metadata of pushed values
+type formatWriter struct {
+	WriteStream
+	format Format
+}
+
+// PushMeta implements MetaWriter
+func (w *formatWriter) PushMeta(v RawValue, m *Meta) bool {
+	if m != nil {
+		m.Format = w.format
+	}
+	return PushMeta(w.WriteStream, v, m)
+}
+
+func (w *formatWriter) pushError(err error) bool {
+	return pushError(w.WriteStream, err)
+}
+
+// ReadFromTaskWith creates a StreamTask to read values from a Reader using a custom Decoder
+func ReadFromTaskWith(r io.Reader, newDecoder DecoderFunc) ProducerFunc {
+	return func(s WriteStream) error {
+		return readValues(s, r, "", newDecoder)
+	}
+}
 
-			if !s.Push(v) {
+// readValues decodes values from a Reader to a stream.
+// Decode errors are reported as *DecodeError.
+func readValues(s WriteStream, r io.Reader, filename string, newDecoder DecoderFunc) error {
+	lr := &lineReader{r: r}
+	dec := newDecoder(lr)
+	for document := 1; ; document++ {
+		var (
+			v    RawValue
+			meta = &Meta{Filename: filename, Document: document}
+			err  error
+		)
+		if d, ok := dec.(*yamlDecoder); ok {
+			v, meta.Comments, err = d.DecodeValue()
+		} else {
+			err = dec.Decode(&v)
+		}
+		if err != nil {
+			if err == io.EOF {
 				return nil
 			}
+			// The YAML decoder has read the whole document if it failed to convert it to JSON
+			var nodeErr *DecodeError
+			_, skip := dec.(*yamlDecoder)
+			skip = skip && errors.As(err, &nodeErr)
+			err = decodeError(err, lr, filename, document)
+			if skip && pushError(s, err) {
+				continue
+			}
+			return err
+		}
+		switch d := dec.(type) {
+		case lineDecoder:
+			meta.Line = d.Line()
+		case *json.Decoder:
+			meta.Line, _ = lr.position(d.InputOffset() - int64(len(v)))
+		}
+		if v == "" {
+			v = "null"
+		}
+
+		if !PushMeta(s, v, meta) {
+			return nil
 		}
 	}
 }
 
+// StreamWrite creates a StreamTask to write values to a Writer in an output format
+func StreamWrite(w io.WriteCloser, output Output, options JSONOptions, csv *CSVOptions) ConsumerFunc {
+	switch output {
+	case OutputJSON:
+		return StreamWriteJSONWith(w, options)
+	case OutputJSONPretty:
+		if options.Indent == 0 && !options.Tab {
+			options.Indent = 2
+		}
+		return StreamWriteJSONWith(w, options)
+	case OutputTOML:
+		return StreamWriteTOML(w)
+	case OutputRaw:
+		return StreamWriteRaw(w, '\n')
+	case OutputRaw0:
+		return StreamWriteRaw(w, 0)
+	case OutputCSV, OutputTSV:
+		if csv == nil {
+			csv = &CSVOptions{}
+		}
+		return StreamWriteCSV(w, output, csv)
+	default:
+		return StreamWriteYAML(w)
+	}
+}
+
 // StreamWriteJSON creates a StreamTask to write values as JSON to a Writer
 func StreamWriteJSON(w io.WriteCloser) ConsumerFunc {
+	return StreamWriteJSONWith(w, JSONOptions{})
+}
+
+// JSONOptions are options for JSON output
+type JSONOptions struct {
+	// Indent values with Indent spaces, values are compact if zero
+	Indent int
+	// Tab indents values with tabs
+	Tab bool
+	// SortKeys sorts object keys
+	SortKeys bool
+	// ASCII escapes non-ASCII characters
+	ASCII bool
+}
+
+func (o *JSONOptions) indent() string {
+	if o.Tab {
+		return "\t"
+	}
+	return strings.Repeat(" ", o.Indent)
+}
+
+// StreamWriteJSONWith creates a StreamTask to write values as JSON to a Writer using options
+func StreamWriteJSONWith(w io.WriteCloser, options JSONOptions) ConsumerFunc {
+	indent := options.indent()
 	return func(s ReadStream) error {
 		defer w.Close()
 		var (
@@ -180,13 +392,28 @@ func StreamWriteJSON(w io.WriteCloser) ConsumerFunc {
 				// No more stream values
 				return nil
 			}
+			if options.SortKeys {
+				var err error
+				if v, err = v.SortKeys(); err != nil {
+					return err
+				}
+			}
 			data = append(data[:0], string(v)...) // Avoid allocations
 
-			// Compact JSON output
 			buf.Reset()
-			if err := json.Compact(&buf, data); err != nil {
+			if indent != "" {
+				if err := json.Indent(&buf, data, "", indent); err != nil {
+					return err
+				}
+			} else if err := json.Compact(&buf, data); err != nil {
+				// Compact JSON output
 				return err
 			}
+			if options.ASCII {
+				data = appendASCII(data[:0], buf.Bytes())
+				buf.Reset()
+				buf.Write(data)
+			}
 			// One value per line
 			buf.WriteByte('\n')
 			if _, err := buf.WriteTo(w); err != nil {
@@ -196,34 +423,87 @@ func StreamWriteJSON(w io.WriteCloser) ConsumerFunc {
 	}
 }
 
-// StreamWriteYAML creates a StreamTask to write values as YAML to a Writer
-func StreamWriteYAML(w io.WriteCloser) ConsumerFunc {
-	const newDocSeparator = "---\n"
+// appendASCII escapes non-ASCII characters of JSON data
+func appendASCII(dst, data []byte) []byte {
+	const hex = "0123456789abcdef"
+	escape := func(dst []byte, r rune) []byte {
+		return append(dst, '\\', 'u', hex[r>>12&0xf], hex[r>>8&0xf], hex[r>>4&0xf], hex[r&0xf])
+	}
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		switch {
+		case r < utf8.RuneSelf:
+			dst = append(dst, data[0])
+		case r > 0xffff:
+			r1, r2 := utf16.EncodeRune(r)
+			dst = escape(escape(dst, r1), r2)
+		default:
+			dst = escape(dst, r)
+		}
+		data = data[size:]
+	}
+	return dst
+}
+
+// StreamWriteRaw creates a StreamTask to write values to a Writer separated by sep.
+// Strings are written unquoted, other values as compact JSON.
+func StreamWriteRaw(w io.WriteCloser, sep byte) ConsumerFunc {
+	return func(s ReadStream) error {
+		defer w.Close()
+		buf := bytes.Buffer{}
+		for {
+			v, ok := s.Next()
+			if !ok {
+				// No more stream values
+				return nil
+			}
+			buf.Reset()
+			if v.Kind() == String {
+				var str string
+				if err := json.Unmarshal([]byte(v), &str); err != nil {
+					return err
+				}
+				buf.WriteString(str)
+			} else if err := json.Compact(&buf, []byte(v.MarshalJSONString())); err != nil {
+				return err
+			}
+			buf.WriteByte(sep)
+			if _, err := buf.WriteTo(w); err != nil {
+				return err
+			}
+		}
+	}
+}
 
+// StreamWriteYAML creates a StreamTask to write values as YAML to a Writer.
+// YAML comments of the values are written back for paths that still exist.
+func StreamWriteYAML(w io.WriteCloser) ConsumerFunc {
 	return func(s ReadStream) (err error) {
 		// Close output when done
 		// Not sure this is the responsibility of the task
 		defer w.Close()
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
 		for numValues := 0; ; numValues++ {
 			v, ok := s.Next()
 			if !ok {
 				// No more stream values
+				if numValues > 0 {
+					// Flush encoder, yaml.Encoder fails on empty streams
+					err = enc.Close()
+				}
 				return
 			}
-
-			// Separate YAML documents
-			if numValues > 0 {
-				_, err = io.WriteString(w, newDocSeparator)
-				if err != nil {
-					return
-				}
+			var comments *Comments
+			if meta := MetaOf(s); meta != nil {
+				comments = meta.Comments
 			}
-			// Encode a single YAML document
-			// Cannot use one encoder for all values because of a yaml.Encoder bug
-			// with multiple documents and scalar values
-			enc := yaml.NewEncoder(w)
-			if err = enc.Encode(v); err != nil {
-				return
+			doc, err := YAMLDocument(v, comments)
+			if err != nil {
+				return err
+			}
+			if err := enc.Encode(doc); err != nil {
+				return err
 			}
 		}
 	}
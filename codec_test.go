@@ -0,0 +1,97 @@
+package ycat_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alxarch/ycat"
+)
+
+func TestReadFromTask_DecodeError(t *testing.T) {
+	tests := []struct {
+		Input  string
+		Format ycat.Format
+		Error  string
+	}{
+		{"{\"a\":1}\n{\"b\":\n  x}", ycat.JSON, "<stdin>:3:3: invalid character 'x' looking for beginning of value"},
+		{"1\n[1,\n2,,]", ycat.JSON, "<stdin>:3:3: invalid character ',' looking for beginning of value"},
+		{"{\"a\":1}\n\"abc", ycat.JSON, "<stdin>:2:5: unexpected EOF"},
+		{"a: 1\n---\nb: 2\n  c: 3\n", ycat.YAML, "<stdin>:4: mapping values are not allowed in this context"},
+		{"a: 1\n---\nb: .nan\n", ycat.YAML, "<stdin>:3:4: number .nan cannot be represented in JSON"},
+		{"a = 1\nb = \n", ycat.TOML, "<stdin>:2:5: expected value but found '\\n' instead"},
+		{"a,b\n1,\"2\n", ycat.CSV, "<stdin>:2:6: extraneous or missing \" in quoted-field"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Input, func(t *testing.T) {
+			ctx := context.Background()
+			p := ycat.MakePipeline(ctx,
+				ycat.ReadFromTask(strings.NewReader(tt.Input), tt.Format),
+				ycat.ConsumerFunc(func(s ycat.ReadStream) error {
+					for {
+						if _, ok := s.Next(); !ok {
+							return nil
+						}
+					}
+				}),
+			)
+			var err error
+			for e := range p.Errors() {
+				if e != nil && err == nil {
+					err = e
+				}
+			}
+			var decodeErr *ycat.DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Fatalf("ReadFromTask() error = %v, want *DecodeError", err)
+			}
+			if got := decodeErr.Error(); got != tt.Error {
+				t.Errorf("ReadFromTask() error = %q, want %q", got, tt.Error)
+			}
+		})
+	}
+}
+
+func TestReadFromTask_Meta(t *testing.T) {
+	tests := []struct {
+		Input  string
+		Format ycat.Format
+		Lines  []int
+	}{
+		{"a: 1\n---\n# head\nb: 2\n", ycat.YAML, []int{1, 4}},
+		{"1\n\n  [2,\n3] {}", ycat.JSON, []int{1, 3, 4}},
+		{"a,b\n1,\"x\ny\"\n2,z\n", ycat.CSV, []int{2, 4}},
+		{"a\nb\n", ycat.TEXT, []int{1, 2}},
+		{"a = 1\n", ycat.TOML, []int{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Input, func(t *testing.T) {
+			var metas []ycat.Meta
+			p := ycat.MakePipeline(context.Background(),
+				ycat.ReadFromTask(strings.NewReader(tt.Input), tt.Format),
+				ycat.ConsumerFunc(func(s ycat.ReadStream) error {
+					for {
+						if _, ok := s.Next(); !ok {
+							return nil
+						}
+						metas = append(metas, *ycat.MetaOf(s))
+					}
+				}),
+			)
+			for err := range p.Errors() {
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if len(metas) != len(tt.Lines) {
+				t.Fatalf("Wrong number of values: %d != %d", len(metas), len(tt.Lines))
+			}
+			for i, m := range metas {
+				if m.Format != tt.Format || m.Document != i+1 || m.Line != tt.Lines[i] {
+					t.Errorf("Wrong meta %d: %+v", i, m)
+				}
+			}
+		})
+	}
+}
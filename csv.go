@@ -0,0 +1,218 @@
+package ycat
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// CSVOptions are options for CSV/TSV input and output
+type CSVOptions struct {
+	// NoHeader reads rows as arrays and skips the header on output
+	NoHeader bool
+	// InferTypes converts numbers and booleans in input cells
+	InferTypes bool
+}
+
+// Decoder returns a DecoderFunc for CSV or TSV format
+func (o *CSVOptions) Decoder(format Format) DecoderFunc {
+	return func(r io.Reader) Decoder {
+		return NewCSVDecoder(r, format, *o)
+	}
+}
+
+func csvComma(tsv bool) rune {
+	if tsv {
+		return '\t'
+	}
+	return ','
+}
+
+type csvDecoder struct {
+	r       *csv.Reader
+	options CSVOptions
+	header  []string
+	line    int
+}
+
+// Line implements lineDecoder
+func (d *csvDecoder) Line() int {
+	return d.line
+}
+
+// NewCSVDecoder creates a Decoder reading one value per row from CSV or TSV input.
+// Rows are decoded to objects keyed by the header unless options.NoHeader is set.
+func NewCSVDecoder(r io.Reader, format Format, options CSVOptions) Decoder {
+	cr := csv.NewReader(r)
+	cr.Comma = csvComma(format == TSV)
+	if cr.Comma == '\t' {
+		cr.LazyQuotes = true
+	}
+	return &csvDecoder{
+		r:       cr,
+		options: options,
+	}
+}
+
+// Decode implements Decoder
+func (d *csvDecoder) Decode(x interface{}) error {
+	if d.header == nil && !d.options.NoHeader {
+		header, err := d.r.Read()
+		if err != nil {
+			return err
+		}
+		d.header = header
+	}
+	record, err := d.r.Read()
+	if err != nil {
+		return err
+	}
+	d.line, _ = d.r.FieldPos(0)
+	var row interface{}
+	if d.options.NoHeader {
+		arr := make([]interface{}, len(record))
+		for i, cell := range record {
+			arr[i] = d.cell(cell)
+		}
+		row = arr
+	} else {
+		m := make(Map, len(record))
+		for i, cell := range record {
+			m[i] = yaml.MapItem{Key: d.header[i], Value: d.cell(cell)}
+		}
+		row = m
+	}
+	v, err := encodeRawValue(row)
+	if err != nil {
+		return err
+	}
+	if raw, ok := x.(*RawValue); ok {
+		*raw = v
+		return nil
+	}
+	return json.Unmarshal([]byte(v), x)
+}
+
+func (d *csvDecoder) cell(s string) interface{} {
+	if d.options.InferTypes {
+		switch s {
+		case "true":
+			return true
+		case "false":
+			return false
+		}
+		if _, err := strconv.ParseFloat(s, 64); err == nil && json.Valid([]byte(s)) {
+			return json.Number(s)
+		}
+	}
+	return s
+}
+
+// StreamWriteCSV creates a StreamTask to write values as CSV or TSV rows to a Writer.
+// Objects are flattened to columns using dotted keys, in the order keys are first seen.
+// All values are buffered to determine the columns before writing.
+func StreamWriteCSV(w io.WriteCloser, output Output, options *CSVOptions) ConsumerFunc {
+	return func(s ReadStream) error {
+		defer w.Close()
+		var (
+			rows    []interface{}
+			columns []string
+			index   = make(map[string]int)
+		)
+		for {
+			v, ok := s.Next()
+			if !ok {
+				break
+			}
+			x, err := decodeRawValue(v)
+			if err != nil {
+				return err
+			}
+			if m, ok := x.(Map); ok {
+				flat := flattenMap(nil, "", m)
+				for i := range flat {
+					key := flat[i].Key.(string)
+					if _, ok := index[key]; !ok {
+						index[key] = len(columns)
+						columns = append(columns, key)
+					}
+				}
+				x = flat
+			}
+			rows = append(rows, x)
+		}
+		cw := csv.NewWriter(w)
+		cw.Comma = csvComma(output == OutputTSV)
+		if len(columns) > 0 && !options.NoHeader {
+			if err := cw.Write(columns); err != nil {
+				return err
+			}
+		}
+		var record []string
+		for _, row := range rows {
+			switch row := row.(type) {
+			case Map:
+				record = append(record[:0], make([]string, len(columns))...)
+				for i := range row {
+					cell, err := csvCell(row[i].Value)
+					if err != nil {
+						return err
+					}
+					record[index[row[i].Key.(string)]] = cell
+				}
+			case []interface{}:
+				record = record[:0]
+				for _, x := range row {
+					cell, err := csvCell(x)
+					if err != nil {
+						return err
+					}
+					record = append(record, cell)
+				}
+			default:
+				cell, err := csvCell(row)
+				if err != nil {
+					return err
+				}
+				record = append(record[:0], cell)
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+}
+
+// flattenMap flattens nested objects using dotted keys
+func flattenMap(flat Map, prefix string, m Map) Map {
+	for i := range m {
+		key := prefix + m[i].Key.(string)
+		if sub, ok := m[i].Value.(Map); ok && len(sub) > 0 {
+			flat = flattenMap(flat, key+".", sub)
+			continue
+		}
+		flat = append(flat, yaml.MapItem{Key: key, Value: m[i].Value})
+	}
+	return flat
+}
+
+func csvCell(x interface{}) (string, error) {
+	switch x := x.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return x, nil
+	case json.Number:
+		return x.String(), nil
+	case bool:
+		return strconv.FormatBool(x), nil
+	default:
+		v, err := encodeRawValue(x)
+		return string(v), err
+	}
+}
@@ -0,0 +1,328 @@
+package ycat
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/BurntSushi/toml"
+)
+
+// DecodeError is an error decoding a value from an input
+type DecodeError struct {
+	// Filename is the path of the input, empty for stdin
+	Filename string
+	// Document is the number of the document in the input starting at 1
+	Document int
+	// Line and Column of the error starting at 1, zero if unknown
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	name := e.Filename
+	if name == "" {
+		name = "<stdin>"
+	}
+	switch {
+	case e.Line > 0 && e.Column > 0:
+		return fmt.Sprintf("%s:%d:%d: %s", name, e.Line, e.Column, e.Err)
+	case e.Line > 0:
+		return fmt.Sprintf("%s:%d: %s", name, e.Line, e.Err)
+	case e.Document > 0:
+		return fmt.Sprintf("%s: document %d: %s", name, e.Document, e.Err)
+	default:
+		return fmt.Sprintf("%s: %s", name, e.Err)
+	}
+}
+
+// Unwrap returns the underlying error
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// lineReader records line offsets of the data read to find error positions
+type lineReader struct {
+	r      io.Reader
+	offset int64
+	lines  []int64 // offsets of line starts after the first line
+}
+
+func (r *lineReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	for i, c := range p[:n] {
+		if c == '\n' {
+			r.lines = append(r.lines, r.offset+int64(i)+1)
+		}
+	}
+	r.offset += int64(n)
+	return n, err
+}
+
+// position converts an offset to line and column
+func (r *lineReader) position(offset int64) (line, column int) {
+	i := sort.Search(len(r.lines), func(i int) bool {
+		return r.lines[i] > offset
+	})
+	start := int64(0)
+	if i > 0 {
+		start = r.lines[i-1]
+	}
+	return i + 1, int(offset-start) + 1
+}
+
+var (
+	yamlLineError = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+	tomlLineError = regexp.MustCompile(`^toml: line \d+(?: \(last key ".*"\))?: (.*)$`)
+)
+
+// decodeError converts an error returned by a Decoder to a DecodeError
+func decodeError(err error, r *lineReader, filename string, document int) error {
+	e := &DecodeError{
+		Filename: filename,
+		Document: document,
+		Err:      err,
+	}
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		tomlErr   toml.ParseError
+		csvErr    *csv.ParseError
+		nodeErr   *DecodeError
+	)
+	switch {
+	case errors.As(err, &nodeErr):
+		e.Line, e.Column, e.Err = nodeErr.Line, nodeErr.Column, nodeErr.Err
+	case errors.As(err, &syntaxErr):
+		// Offset is after the invalid character
+		e.Line, e.Column = r.position(syntaxErr.Offset - 1)
+	case errors.As(err, &typeErr):
+		e.Line, e.Column = r.position(typeErr.Offset - 1)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		e.Line, e.Column = r.position(r.offset)
+	case errors.As(err, &tomlErr):
+		// A TOML input is read as a single document
+		e.Line, e.Column = r.position(int64(tomlErr.Position.Start))
+		if m := tomlLineError.FindStringSubmatch(tomlErr.Error()); m != nil {
+			e.Err = errors.New(m[1])
+		}
+	case errors.As(err, &csvErr):
+		e.Line, e.Column, e.Err = csvErr.Line, csvErr.Column, csvErr.Err
+	default:
+		if m := yamlLineError.FindStringSubmatch(err.Error()); m != nil {
+			e.Line, _ = strconv.Atoi(m[1])
+			e.Err = errors.New(m[2])
+		}
+	}
+	return e
+}
+
+// EvalError is an error evaluating a Jsonnet snippet or jq filter for an input value
+type EvalError struct {
+	// Filename is the path of the input, empty for stdin
+	Filename string
+	// Document is the number of the value in the input starting at 1
+	Document int
+	// Value is a dump of the input value, empty if not enabled
+	Value string
+	Err   error
+}
+
+func (e *EvalError) Error() string {
+	w := strings.Builder{}
+	if e.Document > 0 {
+		name := e.Filename
+		if name == "" {
+			name = "<stdin>"
+		}
+		fmt.Fprintf(&w, "%s: document %d: ", name, e.Document)
+	}
+	w.WriteString(e.Err.Error())
+	if e.Value != "" {
+		w.WriteString("\ninput value: ")
+		w.WriteString(e.Value)
+	}
+	return w.String()
+}
+
+// Unwrap returns the underlying error
+func (e *EvalError) Unwrap() error {
+	return e.Err
+}
+
+// dumpValue formats a value as compact JSON truncated to size bytes
+func dumpValue(v RawValue, size int) string {
+	buf := bytes.Buffer{}
+	if err := json.Compact(&buf, []byte(v.MarshalJSONString())); err != nil {
+		return ""
+	}
+	data := buf.Bytes()
+	if len(data) <= size {
+		return string(data)
+	}
+	data = data[:size]
+	// Do not split a UTF-8 sequence
+	for len(data) > 0 && !utf8.Valid(data) {
+		data = data[:len(data)-1]
+	}
+	return string(data) + "..."
+}
+
+// newEvalError annotates an evaluation error with the source of the input value
+func newEvalError(err error, v RawValue, meta *Meta, dumpSize int) error {
+	evalErr := EvalError{Err: err}
+	if meta != nil {
+		evalErr.Filename = meta.Filename
+		evalErr.Document = meta.Document
+	}
+	if dumpSize > 0 {
+		evalErr.Value = dumpValue(v, dumpSize)
+	}
+	if evalErr.Document == 0 && evalErr.Value == "" {
+		return err
+	}
+	return &evalErr
+}
+
+// FailedError is the error of a run where some documents failed
+type FailedError struct {
+	Count int
+}
+
+func (e *FailedError) Error() string {
+	if e.Count == 1 {
+		return "1 document failed"
+	}
+	return fmt.Sprintf("%d documents failed", e.Count)
+}
+
+// ErrorLog reports errors of failed documents so that processing can continue
+type ErrorLog struct {
+	w     io.Writer
+	json  bool
+	mu    sync.Mutex
+	count int
+}
+
+// NewErrorLog creates an ErrorLog writing one error per line to w.
+// If ndjson is set errors are written as JSON objects.
+func NewErrorLog(w io.Writer, ndjson bool) *ErrorLog {
+	return &ErrorLog{w: w, json: ndjson}
+}
+
+// errorRecord is the NDJSON format of reported errors
+type errorRecord struct {
+	File     string `json:"file"`
+	Document int    `json:"document,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Message  string `json:"message"`
+	Value    string `json:"value,omitempty"`
+}
+
+// Report logs an error of a failed document
+func (l *ErrorLog) Report(err error) {
+	var line []byte
+	if l.json {
+		var (
+			rec       = errorRecord{Message: err.Error()}
+			decodeErr *DecodeError
+			evalErr   *EvalError
+		)
+		switch {
+		case errors.As(err, &decodeErr):
+			rec = errorRecord{
+				File:     decodeErr.Filename,
+				Document: decodeErr.Document,
+				Line:     decodeErr.Line,
+				Column:   decodeErr.Column,
+				Message:  decodeErr.Err.Error(),
+			}
+		case errors.As(err, &evalErr):
+			rec = errorRecord{
+				File:     evalErr.Filename,
+				Document: evalErr.Document,
+				Message:  evalErr.Err.Error(),
+				Value:    evalErr.Value,
+			}
+		}
+		line, _ = json.Marshal(rec)
+	} else {
+		line = []byte(err.Error())
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.count++
+	l.w.Write(line)
+}
+
+// Count returns the number of reported errors
+func (l *ErrorLog) Count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.count
+}
+
+// Err returns a *FailedError if any errors were reported
+func (l *ErrorLog) Err() error {
+	if n := l.Count(); n > 0 {
+		return &FailedError{Count: n}
+	}
+	return nil
+}
+
+// KeepGoing creates a Producer that reports errors of a task to an ErrorLog instead of failing.
+// Values are not read from an input after a decode error, unless the decoder can skip the failed document.
+func KeepGoing(task Producer, log *ErrorLog) ProducerFunc {
+	return func(s WriteStream) error {
+		if log == nil {
+			return task.Produce(s)
+		}
+		if err := task.Produce(&keepGoingWriter{s, log}); err != nil {
+			log.Report(err)
+		}
+		return nil
+	}
+}
+
+// errorWriter is a writable stream that accepts errors of failed documents so that decoding can continue
+type errorWriter interface {
+	pushError(err error) bool
+}
+
+// pushError reports an error of a failed document to a stream.
+// It returns false if the stream does not accept errors.
+func pushError(s WriteStream, err error) bool {
+	if s, ok := s.(errorWriter); ok {
+		return s.pushError(err)
+	}
+	return false
+}
+
+// keepGoingWriter reports errors of failed documents to an ErrorLog
+type keepGoingWriter struct {
+	WriteStream
+	log *ErrorLog
+}
+
+// PushMeta implements MetaWriter
+func (w *keepGoingWriter) PushMeta(v RawValue, m *Meta) bool {
+	return PushMeta(w.WriteStream, v, m)
+}
+
+func (w *keepGoingWriter) pushError(err error) bool {
+	w.log.Report(err)
+	return true
+}
@@ -0,0 +1,133 @@
+package ycat_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alxarch/ycat"
+)
+
+func TestKeepGoing(t *testing.T) {
+	errorsTo := filepath.Join(t.TempDir(), "errors.json")
+	stdin := strings.NewReader("a: 1\n---\na: 2\n---\na: 3\n")
+	out := &bytes.Buffer{}
+	tasks, _, err := ycat.ParseArgs([]string{
+		"-", "testdata/foo.yaml",
+		"-e", `assert x.a != 2 : "invalid"; x`,
+		"--errors-to", errorsTo,
+		"-o", "j",
+	}, stdin, &nopCloser{out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := ycat.MakePipeline(context.Background(), tasks...)
+	var failed *ycat.FailedError
+	for err := range p.Errors() {
+		if err != nil && !errors.As(err, &failed) {
+			t.Fatal(err)
+		}
+	}
+	if failed == nil || failed.Count != 2 {
+		t.Fatalf("Wrong failed error: %v", failed)
+	}
+	if want := `{"a":1}` + "\n" + `{"a":3}` + "\n"; out.String() != want {
+		t.Errorf("Wrong output: %q != %q", out.String(), want)
+	}
+	data, err := ioutil.ReadFile(errorsTo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Wrong error log: %q", data)
+	}
+	if !strings.HasPrefix(lines[0], `{"file":"","document":2,"message":"RUNTIME ERROR: invalid`) {
+		t.Errorf("Wrong eval error: %s", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], `{"file":"testdata/foo.yaml","document":1,"message":"RUNTIME ERROR:`) {
+		t.Errorf("Wrong eval error: %s", lines[1])
+	}
+}
+
+func TestKeepGoing_Stages(t *testing.T) {
+	type TestCase struct {
+		Args   []string
+		Stdin  string
+		Stdout string
+		Failed int
+	}
+	for _, tc := range []TestCase{
+		{[]string{"--keep-going", "-e", "x", "-o", "j"}, "a: 1\n---\n: bad: [\n", `{"a":1}` + "\n", 1},
+		{[]string{"--keep-going", "-o", "j"}, "a: 1\n---\na: .nan\n---\na: 3\n---\nb: .inf\n", `{"a":1}` + "\n" + `{"a":3}` + "\n", 2},
+		{[]string{"--keep-going", "-y", "-", "-o", "j"}, "a: .nan\n---\na: 2\n", `{"a":2}` + "\n", 1},
+		{[]string{"--keep-going", "--init", "0", "--reduce", `assert x != 2 : "invalid"; acc + x`}, "1\n---\n2\n---\n3\n", "4\n", 1},
+		{[]string{"--keep-going", "--slurp", `error "invalid"`}, "1\n", "", 1},
+		{[]string{"--keep-going", "-j", "-q", "if . == 2 then nan else . end", "-o", "j"}, "1 2 3", "1\n3\n", 1},
+		{[]string{"--keep-going", "--sort-by", `assert x != 2 : "invalid"; -x`, "-o", "j"}, "1\n---\n2\n---\n3\n", "3\n1\n", 1},
+	} {
+		out := &bytes.Buffer{}
+		tasks, _, err := ycat.ParseArgs(tc.Args, strings.NewReader(tc.Stdin), &nopCloser{out})
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := ycat.MakePipeline(context.Background(), tasks...)
+		var failed *ycat.FailedError
+		for err := range p.Errors() {
+			if err != nil && !errors.As(err, &failed) {
+				t.Errorf("%v: %s", tc.Args, err)
+			}
+		}
+		if failed == nil || failed.Count != tc.Failed {
+			t.Errorf("%v: Wrong failed error: %v", tc.Args, failed)
+		}
+		if out.String() != tc.Stdout {
+			t.Errorf("%v: Wrong output: %q != %q", tc.Args, out.String(), tc.Stdout)
+		}
+	}
+}
+
+func TestErrorsTo_Usage(t *testing.T) {
+	errorsTo := filepath.Join(t.TempDir(), "errors.json")
+	if err := ioutil.WriteFile(errorsTo, []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"--errors-to", errorsTo, "--invalid"},
+		{"--errors-to", errorsTo, "--help"},
+		{"--errors-to", errorsTo, "--output-dir", "out"},
+	} {
+		ycat.ParseArgs(args, nil, nil)
+		if data, err := ioutil.ReadFile(errorsTo); err != nil || string(data) != "keep" {
+			t.Errorf("%v: Errors file modified: %q %v", args, data, err)
+		}
+	}
+}
+
+func TestQuery_DumpErrors(t *testing.T) {
+	stdin := strings.NewReader("a: 1\n---\na: foo bar baz\n")
+	tasks, _, err := ycat.ParseArgs([]string{"-q", ".a + 1", "--dump-errors", "10"}, stdin, &nopCloser{ioutil.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := ycat.MakePipeline(context.Background(), tasks...)
+	for e := range p.Errors() {
+		if e != nil && err == nil {
+			err = e
+		}
+	}
+	var evalErr *ycat.EvalError
+	if !errors.As(err, &evalErr) {
+		t.Fatalf("Query error = %v, want *EvalError", err)
+	}
+	if evalErr.Document != 2 {
+		t.Errorf("Wrong document: %d", evalErr.Document)
+	}
+	if want := `{"a":"foo ...`; evalErr.Value != want {
+		t.Errorf("Wrong value dump: %q != %q", evalErr.Value, want)
+	}
+}
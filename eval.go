@@ -3,12 +3,16 @@ package ycat
 //go:generate go run gen.go
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
+	"sync"
 
 	jsonnet "github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
 )
 
 // Eval is the execution environment for Jsonnet
@@ -16,7 +20,13 @@ type Eval struct {
 	Bind         string
 	MaxStackSize int
 	Array        bool
+	Jobs         int
+	DumpSize     int
+	Errors       *ErrorLog
+	JPath        []string
+	MetaVar      string
 	Vars         map[string]Var
+	TLAs         map[string]Var
 	vm           *jsonnet.VM
 }
 
@@ -45,6 +55,14 @@ func (e *Eval) AddVar(typ VarType, name, value string) {
 	e.Vars[name] = Var{typ, value}
 }
 
+// AddTLA adds a top-level argument for snippets that evaluate to a function
+func (e *Eval) AddTLA(typ VarType, name, value string) {
+	if e.TLAs == nil {
+		e.TLAs = make(map[string]Var)
+	}
+	e.TLAs[name] = Var{typ, value}
+}
+
 // Render renders the Jsonnet snippet to be executed
 func (v Var) Render(w *strings.Builder, name string) {
 	w.WriteString("local ")
@@ -53,14 +71,14 @@ func (v Var) Render(w *strings.Builder, name string) {
 	switch v.Type {
 	case FileVar:
 		switch path.Ext(v.Value) {
-		case ".json", ".libsonnet", ".jsonnet":
+		case ".json", ".libsonnet", ".jsonnet", ".yaml", ".yml", ".toml", ".csv", ".tsv":
+			// Data files are converted to JSON by Importer
 			w.WriteString(`import "`)
-		case ".yaml", ".yml":
-			w.WriteString(`importyaml "`)
+			w.WriteString(v.Value)
 		default:
 			w.WriteString(`importstr "`)
+			w.WriteString(v.Value)
 		}
-		w.WriteString(v.Value)
 		w.WriteString("\";\n")
 	default:
 		w.WriteString(`std.extVar("`)
@@ -79,6 +97,9 @@ func (e *Eval) Render(snippet string) string {
 	bind := bindVar(e.Bind)
 	Var{Type: CodeVar}.Render(&w, "_")
 	Var{Type: CodeVar}.Render(&w, bind)
+	if e.MetaVar != "" {
+		w.WriteString("local " + e.MetaVar + " = std.extVar(\"" + metaExtVar + "\");\n")
+	}
 	w.WriteString(snippet)
 	return w.String()
 }
@@ -89,6 +110,13 @@ func (e *Eval) VM() (vm *jsonnet.VM) {
 		e.vm = jsonnet.MakeVM()
 	}
 	vm = e.vm
+	e.setup(vm)
+	return vm
+
+}
+
+// setup configures a Jsonnet VM
+func (e *Eval) setup(vm *jsonnet.VM) {
 	if e.MaxStackSize > 0 {
 		vm.MaxStack = e.MaxStackSize
 	}
@@ -103,9 +131,76 @@ func (e *Eval) VM() (vm *jsonnet.VM) {
 			vm.ExtVar(name, v.Value)
 		}
 	}
+	for name, v := range e.TLAs {
+		switch v.Type {
+		case FileVar:
+			vm.TLACode(name, `import "`+v.Value+`"`)
+		case CodeVar:
+			vm.TLACode(name, v.Value)
+		default:
+			vm.TLAVar(name, v.Value)
+		}
+	}
+	vm.Importer(&Importer{
+		FileImporter: jsonnet.FileImporter{
+			JPaths: e.jpaths(),
+		},
+	})
+	for _, f := range nativeFuncs {
+		vm.NativeFunction(f)
+	}
 	vm.ExtCode("_", ycatStdLib)
-	return vm
+	vm.ExtCode(metaExtVar, metaValue(nil))
+}
+
+// metaExtVar is the name of the external variable for the metadata of the input value
+const metaExtVar = "__meta"
+
+// metaValue renders the metadata of a value as a Jsonnet object, unknown fields are null
+func metaValue(m *Meta) string {
+	var meta struct {
+		File     *string `json:"file"`
+		Format   *string `json:"format"`
+		Document *int    `json:"document"`
+		Line     *int    `json:"line"`
+	}
+	if m != nil {
+		if m.Filename != "" {
+			meta.File = &m.Filename
+		}
+		if format := m.Format.String(); format != "" {
+			meta.Format = &format
+		}
+		if m.Document > 0 {
+			meta.Document = &m.Document
+		}
+		if m.Line > 0 {
+			meta.Line = &m.Line
+		}
+	}
+	data, _ := json.Marshal(meta)
+	return string(data)
+}
+
+// EnvJPath is the name of the env var for Jsonnet library search paths
+const EnvJPath = "YCAT_JPATH"
+
+// EnvJsonnetPath is the name of the env var for Jsonnet library search paths used by jsonnet
+const EnvJsonnetPath = "JSONNET_PATH"
 
+// jpaths returns the library search paths of the importer.
+// The importer searches paths from last to first so -J paths come last,
+// env var paths are reversed so that the left-most wins as with jsonnet.
+func (e *Eval) jpaths() (paths []string) {
+	for _, name := range []string{EnvJsonnetPath, EnvJPath} {
+		env := filepath.SplitList(os.Getenv(name))
+		for i := len(env) - 1; i >= 0; i-- {
+			if env[i] != "" {
+				paths = append(paths, env[i])
+			}
+		}
+	}
+	return append(paths, e.JPath...)
 }
 
 // DefaultInputVar is the default name for the stream value
@@ -130,24 +225,308 @@ func (e *Eval) SnippetFromFile(filename string) StreamTask {
 
 // EvalSnippetTask transforms a stream of input values with Jsonnet
 func (e *Eval) Snippet(filename, snippet string) StreamTask {
-	bind := bindVar(e.Bind)
-	vm := e.VM()
 	snippet = e.Render(snippet)
+	tla := e.inputTLA(filename, snippet)
+	if e.Jobs > 1 {
+		return e.parallelSnippet(filename, snippet, tla, e.Jobs)
+	}
+	vm := e.snippetVM()
 	return StreamFunc(func(s Stream) error {
 		for {
 			v, ok := s.Next()
 			if !ok {
 				return nil
 			}
-			vm.ExtCode(bind, v.MarshalJSONString())
+			out, err := e.evaluate(vm, filename, snippet, v, MetaOf(s), tla)
+			if err != nil {
+				err = newEvalError(err, v, MetaOf(s), e.DumpSize)
+				if e.Errors == nil {
+					return err
+				}
+				e.Errors.Report(err)
+				continue
+			}
+			if !s.Push(out) {
+				return nil
+			}
+		}
+	})
+}
+
+// inputTLA checks if a rendered snippet evaluates to a function with a parameter for the input value
+func (e *Eval) inputTLA(filename, snippet string) bool {
+	node, err := jsonnet.SnippetToAST(filename, snippet)
+	if err != nil {
+		// Reported on evaluation
+		return false
+	}
+	bind := ast.Identifier(bindVar(e.Bind))
+	for {
+		switch n := node.(type) {
+		case *ast.Local:
+			node = n.Body
+			continue
+		case *ast.Function:
+			for _, name := range n.Parameters.Required {
+				if name == bind {
+					return true
+				}
+			}
+			for _, p := range n.Parameters.Optional {
+				if p.Name == bind {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// snippetVM returns a new VM for a stage.
+// Stages run concurrently binding variables for each value so they cannot share a VM.
+func (e *Eval) snippetVM() *jsonnet.VM {
+	vm := jsonnet.MakeVM()
+	e.setup(vm)
+	return vm
+}
+
+// evaluate evaluates a rendered snippet binding the input value and its metadata.
+// If tla is set the input value is also passed as a top-level argument.
+func (e *Eval) evaluate(vm *jsonnet.VM, filename, snippet string, v RawValue, meta *Meta, tla bool) (RawValue, error) {
+	result, err := e.evaluateRaw(vm, filename, snippet, v, meta, tla)
+	if err != nil {
+		return "", err
+	}
+	// Jsonnet sorts object keys, restore the order of the input value
+	return result.OrderKeys(v)
+}
+
+// evaluateRaw is the same as evaluate without restoring key order
+func (e *Eval) evaluateRaw(vm *jsonnet.VM, filename, snippet string, v RawValue, meta *Meta, tla bool) (RawValue, error) {
+	vm.ExtCode(bindVar(e.Bind), v.MarshalJSONString())
+	vm.ExtCode(metaExtVar, metaValue(meta))
+	if tla {
+		vm.TLACode(bindVar(e.Bind), v.MarshalJSONString())
+	}
+	result, err := vm.EvaluateSnippet(filename, snippet)
+	if err != nil {
+		return "", err
+	}
+	return RawValue(result), nil
+}
+
+// DefaultAccVar is the name of the accumulator variable in reduce snippets
+const DefaultAccVar = "acc"
+
+// Reduce creates a StreamTask that folds all stream values to a single value.
+// The snippet is evaluated for each value with the result of the previous evaluation bound to acc.
+// The accumulator is initialized with the init Jsonnet code, null if empty.
+func (e *Eval) Reduce(filename, snippet, init string) StreamTask {
+	if init == "" {
+		init = "null"
+	}
+	vm := e.snippetVM()
+	snippet = e.Render("local " + DefaultAccVar + " = std.extVar(\"" + DefaultAccVar + "\");\n" + snippet)
+	return StreamFunc(func(s Stream) error {
+		vm.ExtCode(DefaultAccVar, init)
+		acc := RawValue("")
+		for {
+			v, ok := s.Next()
+			if !ok {
+				break
+			}
+			vm.ExtCode(bindVar(e.Bind), v.MarshalJSONString())
+			vm.ExtCode(metaExtVar, metaValue(MetaOf(s)))
 			result, err := vm.EvaluateSnippet(filename, snippet)
 			if err != nil {
+				err = newEvalError(err, v, MetaOf(s), e.DumpSize)
+				if e.Errors == nil {
+					return err
+				}
+				// Skip the value keeping the previous result
+				e.Errors.Report(err)
+				continue
+			}
+			// Keys of the accumulator keep their order, new keys follow the order of the input
+			if result, err := RawValue(result).OrderKeys(v); err != nil {
+				return err
+			} else if acc, err = result.OrderKeys(acc); err != nil {
 				return err
 			}
-			if !s.Push(RawValue(result)) {
-				return nil
+			vm.ExtCode(DefaultAccVar, acc.MarshalJSONString())
+		}
+		if acc == "" {
+			// No values, evaluate the initial value
+			vm.ExtCode(bindVar(e.Bind), "null")
+			vm.ExtCode(metaExtVar, metaValue(nil))
+			result, err := vm.EvaluateSnippet(filename, e.Render("std.extVar(\""+DefaultAccVar+"\")"))
+			if err != nil {
+				return err
+			}
+			acc = RawValue(result)
+		}
+		PushMeta(s, acc, nil)
+		return nil
+	})
+}
+
+// Slurp creates a StreamTask that evaluates a snippet once with all stream values bound as an array.
+// If the result is an array each item is pushed as a separate value.
+// Result items equal to an input value keep the key order of the input.
+func (e *Eval) Slurp(filename, snippet string) StreamTask {
+	snippet = e.Render(snippet)
+	tla := e.inputTLA(filename, snippet)
+	vm := e.snippetVM()
+	return StreamFunc(func(s Stream) error {
+		var values []RawValue
+		inputs := make(map[string]RawValue)
+		for {
+			v, ok := s.Next()
+			if !ok {
+				break
+			}
+			values = append(values, v)
+			if key, err := canonicalJSON(v); err == nil {
+				if _, ok := inputs[key]; !ok {
+					inputs[key] = v
+				}
+			}
+		}
+		v := RawValueArray(values...)
+		result, err := e.evaluateRaw(vm, filename, snippet, v, nil, tla)
+		if err != nil {
+			err = newEvalError(err, v, nil, e.DumpSize)
+			if e.Errors == nil {
+				return err
+			}
+			e.Errors.Report(err)
+			return nil
+		}
+		_, err = spreadValue(&inputOrder{s, inputs}, result, nil)
+		return err
+	})
+}
+
+// inputOrder restores the key order of pushed values that are equal to an input value
+type inputOrder struct {
+	WriteStream
+	inputs map[string]RawValue
+}
+
+// PushMeta implements MetaWriter
+func (w *inputOrder) PushMeta(v RawValue, m *Meta) bool {
+	if key, err := canonicalJSON(v); err == nil {
+		if input, ok := w.inputs[key]; ok {
+			if ordered, err := v.OrderKeys(input); err == nil {
+				v = ordered
+			}
+		}
+	}
+	return PushMeta(w.WriteStream, v, m)
+}
+
+// canonicalJSON encodes a value with sorted object keys
+func canonicalJSON(v RawValue) (string, error) {
+	dec := json.NewDecoder(strings.NewReader(string(v)))
+	dec.UseNumber()
+	var x interface{}
+	if err := dec.Decode(&x); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(x)
+	return string(data), err
+}
+
+// parallelSnippet evaluates a snippet using a pool of Jsonnet VMs.
+// Results are pushed in the same order as the input values.
+func (e *Eval) parallelSnippet(filename, snippet string, tla bool, size int) StreamTask {
+	vms := make([]*jsonnet.VM, size)
+	for i := range vms {
+		vms[i] = jsonnet.MakeVM()
+		e.setup(vms[i])
+	}
+	type job struct {
+		index int
+		value RawValue
+		meta  *Meta
+		err   error
+	}
+	return StreamFunc(func(s Stream) error {
+		var (
+			jobs    = make(chan job)
+			results = make(chan job)
+			// Limit values waiting to be pushed
+			tokens = make(chan struct{}, 2*size)
+			done   = make(chan struct{})
+			wg     sync.WaitGroup
+		)
+		defer close(done)
+		for _, vm := range vms {
+			wg.Add(1)
+			go func(vm *jsonnet.VM) {
+				defer wg.Done()
+				for j := range jobs {
+					out, err := e.evaluate(vm, filename, snippet, j.value, j.meta, tla)
+					if err != nil {
+						j.err = newEvalError(err, j.value, j.meta, e.DumpSize)
+					}
+					j.value = out
+					select {
+					case results <- j:
+					case <-done:
+						return
+					}
+				}
+			}(vm)
+		}
+		go func() {
+			defer close(jobs)
+			for i := 0; ; i++ {
+				select {
+				case tokens <- struct{}{}:
+				case <-done:
+					return
+				}
+				v, ok := s.Next()
+				if !ok {
+					return
+				}
+				select {
+				case jobs <- job{index: i, value: v, meta: MetaOf(s)}:
+				case <-done:
+					return
+				}
+			}
+		}()
+		go func() {
+			wg.Wait()
+			close(results)
+		}()
+
+		pending := make(map[int]job)
+		next := 0
+		for j := range results {
+			if j.err != nil && e.Errors == nil {
+				return j.err
+			}
+			pending[j.index] = j
+			for {
+				j, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				next++
+				if j.err != nil {
+					// Report errors in input order
+					e.Errors.Report(j.err)
+				} else if !PushMeta(s, j.value, j.meta) {
+					return nil
+				}
+				<-tokens
 			}
 		}
+		return nil
 	})
 }
 
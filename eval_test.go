@@ -0,0 +1,166 @@
+package ycat_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/alxarch/ycat"
+)
+
+func evalSnippet(t *testing.T, snippet string) string {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	e := ycat.Eval{}
+	p := ycat.MakePipeline(context.Background(),
+		ycat.ProducerFunc(ycat.NullStream{}.Produce),
+		e.Snippet("test.jsonnet", snippet),
+		ycat.StreamWriteJSON(&nopCloser{buf}),
+	)
+	for err := range p.Errors() {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.String()
+}
+
+func TestStdLib(t *testing.T) {
+	tests := []struct {
+		Snippet string
+		Want    string
+	}{
+		{`_.len("foo")`, `3`},
+		{`_.has([1, 2], 2)`, `true`},
+		{`_.has({a: 1}, "a")`, `true`},
+		{`_.has("foobar", "oba")`, `true`},
+		{`_.has(42, 4)`, `false`},
+		{`_.get({a: 1}, "a")`, `1`},
+		{`_.get({a: 1}, "b", 2)`, `2`},
+		{`_.sum([1, 2, 3])`, `6`},
+		{`_.avg([1, 2, 3])`, `2`},
+		{`_.avg([])`, `0`},
+		{`_.skipWhile(" ", "  foo ")`, `"foo "`},
+		{`_.skipWhile(function(x) x < 3, [1, 2, 3, 1])`, `[3,1]`},
+		{`_.takeWhile("a", "aab")`, `"aa"`},
+		{`_.takeWhile(function(x) x < 3, [1, 2, 3, 1])`, `[1,2]`},
+		{`_.takeUntil("b", "aab")`, `"aa"`},
+		{`_.skipUntil("b", "aab")`, `"b"`},
+		{`_.indexOf("foo", "o")`, `1`},
+		{`_.indexOf("foo", "x")`, `-1`},
+		{`_.indexOf("foo", "oo")`, `-1`},
+		{`_.indexOf([1, 2, 3], 3)`, `2`},
+		{`_.indexOf([{a: 1}], {a: 1})`, `0`},
+		{`_.indexOf([], 3)`, `-1`},
+		{`_.trunc("foobar", 3)`, `"foo"`},
+		{`_.trunc([1, 2], 3)`, `[1,2]`},
+		{`_.rev("foo")`, `"oof"`},
+		{`_.rev("")`, `""`},
+		{`_.rev([1, 2, 3])`, `[3,2,1]`},
+		{`_.ascii.isLower("a")`, `true`},
+		{`_.ascii.isAlnum("_")`, `false`},
+		{`_.squeeze("a  b--c", " -")`, `"a b-c"`},
+		{`_.squeeze("a -b", " -")`, `"a b"`},
+		{`_.squeeze([1, 1, 2, 2], [1])`, `[1,2,2]`},
+		{`_.normalize("  foo \n\t bar  ")`, `"foo bar"`},
+		{`_.normalize("")`, `""`},
+		{`_.trimLeft("  foo  ")`, `"foo  "`},
+		{`_.trimRight("  foo  ")`, `"  foo"`},
+		{`_.trim("  foo  ")`, `"foo"`},
+		{`_.trim("xxfooxx", "x")`, `"foo"`},
+		{`_.trim("xyfooyx", ["x", "y"])`, `"foo"`},
+		{`_.trim("xyfooyx", {x: 1})`, `"yfooy"`},
+		{`_.trim("xfoox", 120)`, `"foo"`},
+		{`_.trim("xfoox", null)`, `"xfoox"`},
+		{`_.trim("12foo21", _.ascii.isDigit)`, `"foo"`},
+		{`_.trim("   ")`, `""`},
+		{`_.trim("ääfooää", "ä")`, `"foo"`},
+		{`_.k8s.name("Foo Bar_baz")`, `"foo-bar-baz"`},
+		{`_.k8s.name("--42foo--bar--")`, `"foo-bar"`},
+		{`_.k8s.name("Ünïcode")`, `"n-code"`},
+		{`std.length(_.k8s.name(std.join("", std.makeArray(300, function(i) "a"))))`, `253`},
+		{`_.k8s.namespace({metadata: {name: "foo"}}, "Bar")`, `{"metadata":{"namespace":"bar"}}`},
+		{`_.k8s.namespace({metadata: {namespace: "foo"}}, "Bar", false)`, `{"metadata":{"namespace":"foo"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Snippet, func(t *testing.T) {
+			if got := evalSnippet(t, tt.Snippet); got != tt.Want+"\n" {
+				t.Errorf("Wrong output: %q != %q", got, tt.Want+"\n")
+			}
+		})
+	}
+}
+
+func TestEval_Jobs(t *testing.T) {
+	const size = 100
+	in, want := &bytes.Buffer{}, &bytes.Buffer{}
+	for i := 0; i < size; i++ {
+		fmt.Fprintf(in, "%d\n", i)
+		fmt.Fprintf(want, "%d\n", 2*i)
+	}
+	out := &bytes.Buffer{}
+	e := ycat.Eval{Jobs: 4}
+	p := ycat.MakePipeline(context.Background(),
+		ycat.ReadFromTask(in, ycat.JSON),
+		e.Snippet("test.jsonnet", "2 * x"),
+		ycat.StreamWriteJSON(&nopCloser{out}),
+	)
+	for err := range p.Errors() {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if out.String() != want.String() {
+		t.Errorf("Wrong output: %q != %q", out.String(), want.String())
+	}
+}
+
+func TestEval_Error(t *testing.T) {
+	for _, jobs := range []int{1, 4} {
+		e := ycat.Eval{Jobs: jobs, DumpSize: 10}
+		p := ycat.MakePipeline(context.Background(),
+			ycat.ReadFromTask(strings.NewReader("a: 1\n---\na: 2\nb: foo bar baz\n---\na: 3\n"), ycat.YAML),
+			e.Snippet("test.jsonnet", `assert x.a != 2 : "invalid"; x`),
+			ycat.StreamWriteJSON(&nopCloser{ioutil.Discard}),
+		)
+		var err error
+		for e := range p.Errors() {
+			if e != nil && err == nil {
+				err = e
+			}
+		}
+		var evalErr *ycat.EvalError
+		if !errors.As(err, &evalErr) {
+			t.Fatalf("Eval error = %v, want *EvalError", err)
+		}
+		if evalErr.Filename != "" || evalErr.Document != 2 {
+			t.Errorf("Wrong source: %q %d", evalErr.Filename, evalErr.Document)
+		}
+		if want := `{"a":2,"b"...`; evalErr.Value != want {
+			t.Errorf("Wrong value dump: %q != %q", evalErr.Value, want)
+		}
+		if msg := err.Error(); !strings.HasPrefix(msg, "<stdin>: document 2: RUNTIME ERROR: invalid") {
+			t.Errorf("Wrong message: %q", msg)
+		}
+	}
+}
+
+func TestEval_JPath(t *testing.T) {
+	const snippet = `(import "greet.libsonnet").greet("foo")`
+	t.Setenv(ycat.EnvJPath, "testdata/vendor")
+	if got := evalSnippet(t, snippet); got != `"hi foo"`+"\n" {
+		t.Errorf("Wrong output: %q", got)
+	}
+	t.Setenv(ycat.EnvJsonnetPath, "testdata/lib")
+	if got := evalSnippet(t, snippet); got != `"hi foo"`+"\n" {
+		t.Errorf("Wrong output: %q", got)
+	}
+	t.Setenv(ycat.EnvJPath, "")
+	if got := evalSnippet(t, snippet); got != `"hello foo"`+"\n" {
+		t.Errorf("Wrong output: %q", got)
+	}
+}
@@ -3,8 +3,14 @@ module github.com/alxarch/ycat
 go 1.21.5
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/google/go-jsonnet v0.12.1
+	github.com/itchyny/gojq v0.12.17
 	gopkg.in/yaml.v2 v2.4.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-require github.com/sergi/go-diff v1.0.0 // indirect
+require (
+	github.com/itchyny/timefmt-go v0.1.6 // indirect
+	github.com/sergi/go-diff v1.0.0 // indirect
+)
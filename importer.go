@@ -0,0 +1,96 @@
+package ycat
+
+import (
+	"path"
+	"strings"
+
+	jsonnet "github.com/google/go-jsonnet"
+)
+
+// RawImportPrefix is the prefix of import paths for data files that are imported without conversion
+const RawImportPrefix = "raw:"
+
+// Importer is a jsonnet.Importer that converts imported YAML, TOML and CSV files to JSON.
+// Multi-document YAML files and CSV/TSV files are imported as arrays.
+// Paths with RawImportPrefix are not converted so that importstr can return the original text of a data file.
+type Importer struct {
+	jsonnet.FileImporter
+	cache map[string]jsonnet.Contents
+}
+
+// Import implements jsonnet.Importer
+func (imp *Importer) Import(importedFrom, importedPath string) (jsonnet.Contents, string, error) {
+	if strings.HasPrefix(importedPath, RawImportPrefix) {
+		contents, foundAt, err := imp.FileImporter.Import(importedFrom, strings.TrimPrefix(importedPath, RawImportPrefix))
+		if err != nil {
+			return contents, foundAt, err
+		}
+		// Keep the prefix so that the original contents do not replace the converted contents
+		return contents, RawImportPrefix + foundAt, nil
+	}
+	contents, foundAt, err := imp.FileImporter.Import(importedFrom, importedPath)
+	if err != nil {
+		return contents, foundAt, err
+	}
+	format := importFormat(foundAt)
+	if format == Auto {
+		return contents, foundAt, nil
+	}
+	// Importers must return the same Contents for each path
+	if c, ok := imp.cache[foundAt]; ok {
+		return c, foundAt, nil
+	}
+	v, err := importValue(contents.String(), foundAt, format)
+	if err != nil {
+		return jsonnet.Contents{}, "", err
+	}
+	if imp.cache == nil {
+		imp.cache = make(map[string]jsonnet.Contents)
+	}
+	c := jsonnet.MakeContents(string(v))
+	imp.cache[foundAt] = c
+	return c, foundAt, nil
+}
+
+// importFormat detects the format of files that need conversion
+func importFormat(filename string) Format {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".yaml", ".yml":
+		return YAML
+	case ".toml":
+		return TOML
+	case ".csv":
+		return CSV
+	case ".tsv":
+		return TSV
+	default:
+		return Auto
+	}
+}
+
+// importValue converts file data to a JSON value
+func importValue(data, filename string, format Format) (RawValue, error) {
+	var values valueList
+	if err := readValues(&values, strings.NewReader(data), filename, FormatDecoder(format)); err != nil {
+		return "", err
+	}
+	switch {
+	case format == CSV || format == TSV:
+		return RawValueArray(values...), nil
+	case len(values) == 0:
+		return "null", nil
+	case len(values) == 1:
+		return values[0], nil
+	default:
+		return RawValueArray(values...), nil
+	}
+}
+
+// valueList is a WriteStream collecting values
+type valueList []RawValue
+
+// Push implements WriteStream
+func (values *valueList) Push(v RawValue) bool {
+	*values = append(*values, v)
+	return true
+}
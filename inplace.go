@@ -0,0 +1,239 @@
+package ycat
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// InPlace is a StreamTask that edits files in place.
+// The values of each file are processed by Tasks and written back to the file in its original format.
+type InPlace struct {
+	Files []InPlaceFile
+	Tasks []StreamTask
+	// Backup is a suffix for a backup copy of edited files
+	Backup string
+	// Check reports files that would change without writing them
+	Check bool
+	// Stdout is where changed files are listed in Check mode
+	Stdout io.Writer
+	// Errors is used to skip failed files
+	Errors *ErrorLog
+	CSV    CSVOptions
+}
+
+// InPlaceFile is a file to edit in place
+type InPlaceFile struct {
+	Path   string
+	Format Format
+}
+
+// ChangedError is the error of a check run where some files would change
+type ChangedError struct {
+	Files []string
+}
+
+func (e *ChangedError) Error() string {
+	if len(e.Files) == 1 {
+		return "1 file would change"
+	}
+	return fmt.Sprintf("%d files would change", len(e.Files))
+}
+
+// Run implements StreamTask
+func (ip *InPlace) Run(s Stream) error {
+	var changed []string
+	for _, f := range ip.Files {
+		ok, err := ip.Edit(f)
+		if err != nil {
+			if ip.Errors == nil {
+				return err
+			}
+			ip.Errors.Report(err)
+			continue
+		}
+		if ok && ip.Check {
+			changed = append(changed, f.Path)
+			if ip.Stdout != nil {
+				fmt.Fprintln(ip.Stdout, f.Path)
+			}
+		}
+	}
+	if changed != nil {
+		return &ChangedError{changed}
+	}
+	return nil
+}
+
+// Edit processes the values of a file and replaces the file if the output values differ.
+// Values are compared after decoding the output so formatting changes alone do not rewrite a file.
+func (ip *InPlace) Edit(f InPlaceFile) (bool, error) {
+	switch f.Path {
+	case "", "-":
+		return false, errors.New("Cannot edit stdin in place")
+	}
+	if f.Format == Auto {
+		f.Format = DetectFormat(f.Path)
+	}
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return false, err
+	}
+	data, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		return false, err
+	}
+	var input valueList
+	out := &bytes.Buffer{}
+	tasks := make([]StreamTask, 0, len(ip.Tasks)+2)
+	tasks = append(tasks, ProducerFunc(func(s WriteStream) error {
+		return readValues(&formatWriter{&inputList{s, &input}, f.Format}, bytes.NewReader(data), f.Path, ip.decoder(f.Format))
+	}))
+	tasks = append(tasks, ip.Tasks...)
+	tasks = append(tasks, ip.output(f.Format, data, nopCloser{out}))
+	p := MakePipeline(context.Background(), tasks...)
+	for e := range p.Errors() {
+		if e != nil && err == nil {
+			err = e
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+	var output valueList
+	if err := readValues(&output, bytes.NewReader(out.Bytes()), f.Path, ip.decoder(f.Format)); err != nil {
+		return false, err
+	}
+	if equalValues(input, output) {
+		return false, nil
+	}
+	if ip.Check {
+		return true, nil
+	}
+	mode := info.Mode().Perm()
+	if ip.Backup != "" {
+		if err := writeFileAtomic(f.Path+ip.Backup, data, mode); err != nil {
+			return false, err
+		}
+	}
+	return true, writeFileAtomic(f.Path, out.Bytes(), mode)
+}
+
+// inputList collects the values pushed to a stream
+type inputList struct {
+	WriteStream
+	values *valueList
+}
+
+// PushMeta implements MetaWriter
+func (s *inputList) PushMeta(v RawValue, m *Meta) bool {
+	s.values.Push(v)
+	return PushMeta(s.WriteStream, v, m)
+}
+
+// equalValues compares values ignoring formatting
+func equalValues(a, b []RawValue) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		x, err := decodeRawValue(a[i])
+		if err != nil {
+			return false
+		}
+		y, err := decodeRawValue(b[i])
+		if err != nil {
+			return false
+		}
+		vx, _ := encodeRawValue(x)
+		vy, _ := encodeRawValue(y)
+		if vx != vy {
+			return false
+		}
+	}
+	return true
+}
+
+func (ip *InPlace) decoder(format Format) DecoderFunc {
+	switch format {
+	case CSV, TSV:
+		return ip.CSV.Decoder(format)
+	default:
+		return FormatDecoder(format)
+	}
+}
+
+// output returns the output task for a file format
+func (ip *InPlace) output(format Format, data []byte, w io.WriteCloser) ConsumerFunc {
+	switch format {
+	case JSON:
+		return StreamWriteJSONWith(w, detectJSONOptions(data))
+	case TOML:
+		return StreamWriteTOML(w)
+	case CSV:
+		return StreamWriteCSV(w, OutputCSV, &ip.CSV)
+	case TSV:
+		return StreamWriteCSV(w, OutputTSV, &ip.CSV)
+	case TEXT:
+		return StreamWriteRaw(w, '\n')
+	default:
+		return StreamWriteYAML(w)
+	}
+}
+
+// detectJSONOptions detects the indentation of JSON data
+func detectJSONOptions(data []byte) JSONOptions {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "\t"):
+			return JSONOptions{Tab: true}
+		case strings.HasPrefix(line, " "):
+			return JSONOptions{Indent: len(line) - len(strings.TrimLeft(line, " "))}
+		}
+	}
+	return JSONOptions{}
+}
+
+// writeFileAtomic writes a file with permissions mode by renaming a temporary file.
+// Symbolic links are resolved so that the target file is replaced instead of the link.
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
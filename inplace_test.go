@@ -0,0 +1,148 @@
+package ycat_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alxarch/ycat"
+)
+
+func runArgs(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	out := &bytes.Buffer{}
+	tasks, _, err := ycat.ParseArgs(args, &bytes.Buffer{}, &nopCloser{out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := ycat.MakePipeline(context.Background(), tasks...)
+	for e := range p.Errors() {
+		if e != nil && err == nil {
+			err = e
+		}
+	}
+	return out.String(), err
+}
+
+func TestInPlace(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.yaml": "# comment\nname: a\n---\nname: b\n",
+		"b.json": "{\n    \"name\": \"c\"\n}\n",
+		"c.json": `{"name":"d"}` + "\n",
+		"d.yaml": "kind: Foo\n",
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+	snippet := `if std.objectHas(x, "name") then x { name: std.asciiUpper(x.name) } else x`
+	out, err := runArgs(t, path("a.yaml"), path("b.json"), path("c.json"), path("d.yaml"), "--check", "-e", snippet)
+	var changed *ycat.ChangedError
+	if !errors.As(err, &changed) || len(changed.Files) != 3 {
+		t.Fatalf("Check error = %v", err)
+	}
+	if want := path("a.yaml") + "\n" + path("b.json") + "\n" + path("c.json") + "\n"; out != want {
+		t.Errorf("Wrong check output: %q != %q", out, want)
+	}
+	if _, err := runArgs(t, path("a.yaml"), path("b.json"), path("c.json"), path("d.yaml"), "-I", "--backup", ".bak", "-e", snippet); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"a.yaml":     "# comment\nname: A\n---\nname: B\n",
+		"a.yaml.bak": files["a.yaml"],
+		"b.json":     "{\n    \"name\": \"C\"\n}\n",
+		"c.json":     `{"name":"D"}` + "\n",
+		"d.yaml":     files["d.yaml"],
+	}
+	for name, data := range want {
+		got, err := ioutil.ReadFile(path(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != data {
+			t.Errorf("Wrong %s contents: %q != %q", name, got, data)
+		}
+	}
+	if _, err := ioutil.ReadFile(path("d.yaml.bak")); err == nil {
+		t.Errorf("Unchanged file backup")
+	}
+}
+
+func TestInPlace_Unchanged(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"k.yaml": "items:\n- a\n- b\nname: \"x\"\n",
+		"c.json": `{"a": 1, "b": [1,2]}` + "\n",
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	k, c := filepath.Join(dir, "k.yaml"), filepath.Join(dir, "c.json")
+	if out, err := runArgs(t, k, c, "--check", "-e", "x"); err != nil || out != "" {
+		t.Errorf("Check identity = %q, %v", out, err)
+	}
+	if _, err := runArgs(t, k, c, "-I", "-e", "x"); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		if got, _ := ioutil.ReadFile(filepath.Join(dir, name)); string(got) != data {
+			t.Errorf("Identity rewrote %s: %q", name, got)
+		}
+	}
+	if _, err := runArgs(t, k, "-I", "--backup", ".bak", "-e", "x + {b: 1}"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"k.yaml", "k.yaml.bak"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mode := info.Mode().Perm(); mode != 0600 {
+			t.Errorf("Wrong %s mode: %v", name, mode)
+		}
+	}
+}
+
+func TestInPlace_Symlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target.yaml")
+	if err := ioutil.WriteFile(target, []byte("a: 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link.yaml")
+	if err := os.Symlink("target.yaml", link); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runArgs(t, link, "-I", "-e", "x + {b: 2}"); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Symlink replaced: %v", err)
+	}
+	if got, _ := ioutil.ReadFile(target); string(got) != "a: 1\nb: 2\n" {
+		t.Errorf("Wrong target contents: %q", got)
+	}
+}
+
+func TestInPlace_NoFiles(t *testing.T) {
+	for _, args := range [][]string{
+		{"-I", "-e", "x"},
+		{"--check"},
+		{"-I", "-", "-e", "x"},
+	} {
+		if _, _, err := ycat.ParseArgs(args, &bytes.Buffer{}, &nopCloser{&bytes.Buffer{}}); err == nil {
+			t.Errorf("%v: No usage error", args)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package ycat
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/itchyny/gojq"
+)
+
+// Query is the execution environment for jq filters
+type Query struct {
+	Names      []string
+	Values     []interface{}
+	Positional []interface{}
+	DumpSize   int
+	Errors     *ErrorLog
+}
+
+// AddArg binds a named argument to a jq variable
+func (q *Query) AddArg(name string, value interface{}) {
+	for i, n := range q.Names {
+		if n == name {
+			q.Values[i] = value
+			return
+		}
+	}
+	q.Names = append(q.Names, name)
+	q.Values = append(q.Values, value)
+}
+
+// AddPositional adds positional arguments to $ARGS.positional
+func (q *Query) AddPositional(values ...interface{}) {
+	q.Positional = append(q.Positional, values...)
+}
+
+// args returns the jq $ARGS value
+func (q *Query) args() map[string]interface{} {
+	named := make(map[string]interface{}, len(q.Names))
+	for i, name := range q.Names {
+		named[name] = q.Values[i]
+	}
+	positional := make([]interface{}, len(q.Positional))
+	copy(positional, q.Positional)
+	return map[string]interface{}{
+		"named":      named,
+		"positional": positional,
+	}
+}
+
+// Compile compiles a jq filter binding arguments to variables
+func (q *Query) Compile(filter string) (*gojq.Code, []interface{}, error) {
+	query, err := gojq.Parse(filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	names := make([]string, 0, len(q.Names)+1)
+	values := make([]interface{}, 0, len(q.Values)+1)
+	for i, name := range q.Names {
+		names = append(names, "$"+name)
+		values = append(values, q.Values[i])
+	}
+	names = append(names, "$ARGS")
+	values = append(values, q.args())
+	code, err := gojq.Compile(query,
+		gojq.WithVariables(names),
+		gojq.WithEnvironLoader(os.Environ),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	return code, values, nil
+}
+
+// FilterFromFile creates a StreamTask that runs a jq filter from a file on each value
+func (q *Query) FilterFromFile(filename string) StreamTask {
+	return StreamFunc(func(s Stream) error {
+		filter, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		task, err := q.Filter(string(filter))
+		if err != nil {
+			return err
+		}
+		return task.Run(s)
+	})
+}
+
+// FilterDeferred creates a StreamTask that runs a jq filter on each value.
+// The filter is parsed immediately but compiled when the task runs,
+// so arguments added after the filter are bound as with FilterFromFile.
+func (q *Query) FilterDeferred(filter string) (StreamTask, error) {
+	if _, err := gojq.Parse(filter); err != nil {
+		return nil, err
+	}
+	return StreamFunc(func(s Stream) error {
+		task, err := q.Filter(filter)
+		if err != nil {
+			return err
+		}
+		return task.Run(s)
+	}), nil
+}
+
+// Filter creates a StreamTask that runs a jq filter on each value.
+// A filter can produce zero or more values for each input value.
+func (q *Query) Filter(filter string) (StreamTask, error) {
+	code, values, err := q.Compile(filter)
+	if err != nil {
+		return nil, err
+	}
+	return StreamFunc(func(s Stream) error {
+		for {
+			v, ok := s.Next()
+			if !ok {
+				return nil
+			}
+			x, err := jqValue(v)
+			if err != nil {
+				err = newEvalError(err, v, MetaOf(s), q.DumpSize)
+				if q.Errors == nil {
+					return err
+				}
+				q.Errors.Report(err)
+				continue
+			}
+			iter := code.Run(x, values...)
+			for {
+				y, ok := iter.Next()
+				if !ok {
+					break
+				}
+				err, _ := y.(error)
+				if err, ok := err.(*gojq.HaltError); ok && err.Value() == nil {
+					return nil
+				}
+				var out RawValue
+				if err == nil {
+					out, err = jqResult(y, v)
+				}
+				if err != nil {
+					err = newEvalError(err, v, MetaOf(s), q.DumpSize)
+					if q.Errors == nil {
+						return err
+					}
+					q.Errors.Report(err)
+					break
+				}
+				if !s.Push(out) {
+					return nil
+				}
+			}
+		}
+	}), nil
+}
+
+// jqValue converts a value to the types used by gojq
+func jqValue(v RawValue) (x interface{}, err error) {
+	dec := json.NewDecoder(strings.NewReader(v.MarshalJSONString()))
+	dec.UseNumber()
+	if err = dec.Decode(&x); err != nil {
+		return nil, err
+	}
+	return jqNumbers(x), nil
+}
+
+func jqNumbers(x interface{}) interface{} {
+	switch x := x.(type) {
+	case json.Number:
+		if n, err := x.Int64(); err == nil && int64(int(n)) == n {
+			return int(n)
+		}
+		f, _ := x.Float64()
+		return f
+	case map[string]interface{}:
+		for k, v := range x {
+			x[k] = jqNumbers(v)
+		}
+	case []interface{}:
+		for i, v := range x {
+			x[i] = jqNumbers(v)
+		}
+	}
+	return x
+}
+
+// jqResult converts a gojq result to a value restoring the key order of the input
+func jqResult(x interface{}, src RawValue) (RawValue, error) {
+	w := strings.Builder{}
+	enc := json.NewEncoder(&w)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(x); err != nil {
+		return "", err
+	}
+	v := RawValue(strings.TrimSuffix(w.String(), "\n"))
+	// Object keys of gojq results are sorted
+	return v.OrderKeys(src)
+}
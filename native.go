@@ -0,0 +1,198 @@
+package ycat
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+
+	jsonnet "github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
+)
+
+// nativeFuncs are Go implementations of the `_` helpers in ycat.libsonnet
+var nativeFuncs = []*jsonnet.NativeFunction{
+	{
+		Name:   "trim",
+		Params: ast.Identifiers{"str", "cutset"},
+		Func: stringFunc(func(s string, cutset interface{}) (interface{}, error) {
+			return strings.TrimFunc(s, cutsetFunc(cutset)), nil
+		}),
+	},
+	{
+		Name:   "trimLeft",
+		Params: ast.Identifiers{"str", "cutset"},
+		Func: stringFunc(func(s string, cutset interface{}) (interface{}, error) {
+			return strings.TrimLeftFunc(s, cutsetFunc(cutset)), nil
+		}),
+	},
+	{
+		Name:   "trimRight",
+		Params: ast.Identifiers{"str", "cutset"},
+		Func: stringFunc(func(s string, cutset interface{}) (interface{}, error) {
+			return strings.TrimRightFunc(s, cutsetFunc(cutset)), nil
+		}),
+	},
+	{
+		Name:   "squeeze",
+		Params: ast.Identifiers{"str", "cutset"},
+		Func: stringFunc(func(s string, cutset interface{}) (interface{}, error) {
+			return squeeze(s, cutsetFunc(cutset)), nil
+		}),
+	},
+	{
+		Name:   "normalize",
+		Params: ast.Identifiers{"str"},
+		Func: stringFunc(func(s string, _ interface{}) (interface{}, error) {
+			return strings.Join(strings.FieldsFunc(s, isASCIISpace), " "), nil
+		}),
+	},
+	{
+		Name:   "rev",
+		Params: ast.Identifiers{"str"},
+		Func: stringFunc(func(s string, _ interface{}) (interface{}, error) {
+			return reverse(s), nil
+		}),
+	},
+	{
+		Name:   "indexOf",
+		Params: ast.Identifiers{"arr", "x"},
+		Func: func(args []interface{}) (interface{}, error) {
+			return float64(indexOf(args[0], args[1])), nil
+		},
+	},
+	{
+		Name:   "k8sName",
+		Params: ast.Identifiers{"str", "maxSize"},
+		Func: stringFunc(func(s string, size interface{}) (interface{}, error) {
+			maxSize, ok := size.(float64)
+			if !ok {
+				return nil, fmt.Errorf("Invalid max name size: %v", size)
+			}
+			return k8sName(s, int(maxSize)), nil
+		}),
+	},
+}
+
+// stringFunc wraps a native function that expects a string as first argument
+func stringFunc(fn func(s string, arg interface{}) (interface{}, error)) func([]interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("Invalid string argument: %v", args[0])
+		}
+		var arg interface{}
+		if len(args) > 1 {
+			arg = args[1]
+		}
+		return fn(s, arg)
+	}
+}
+
+// cutsetFunc mirrors trimFunc in ycat.libsonnet for non-function cutsets
+func cutsetFunc(cutset interface{}) func(r rune) bool {
+	switch cutset := cutset.(type) {
+	case string:
+		return func(r rune) bool {
+			return strings.ContainsRune(cutset, r)
+		}
+	case []interface{}:
+		return func(r rune) bool {
+			c := string(r)
+			for _, x := range cutset {
+				if x == c {
+					return true
+				}
+			}
+			return false
+		}
+	case map[string]interface{}:
+		return func(r rune) bool {
+			_, ok := cutset[string(r)]
+			return ok
+		}
+	case float64:
+		return func(r rune) bool {
+			return float64(r) == cutset
+		}
+	default:
+		return func(rune) bool { return false }
+	}
+}
+
+func isASCIISpace(r rune) bool {
+	switch r {
+	case ' ', '\n', '\t', '\r':
+		return true
+	default:
+		return false
+	}
+}
+
+func squeeze(s string, fn func(r rune) bool) string {
+	w := strings.Builder{}
+	w.Grow(len(s))
+	last := false
+	for _, r := range s {
+		ok := fn(r)
+		if !(ok && last) {
+			w.WriteRune(r)
+		}
+		last = ok
+	}
+	return w.String()
+}
+
+func reverse(s string) string {
+	rs := []rune(s)
+	for i, j := 0, len(rs)-1; i < j; i, j = i+1, j-1 {
+		rs[i], rs[j] = rs[j], rs[i]
+	}
+	return string(rs)
+}
+
+func indexOf(arr, x interface{}) int {
+	switch arr := arr.(type) {
+	case string:
+		c, ok := x.(string)
+		if !ok || utf8.RuneCountInString(c) != 1 {
+			return -1
+		}
+		n := 0
+		for _, r := range arr {
+			if string(r) == c {
+				return n
+			}
+			n++
+		}
+	case []interface{}:
+		for i, y := range arr {
+			if reflect.DeepEqual(x, y) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func k8sName(s string, maxSize int) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', '0' <= r && r <= '9':
+			return r
+		case 'A' <= r && r <= 'Z':
+			return r - 'A' + 'a'
+		default:
+			return '-'
+		}
+	}, s)
+	name = strings.TrimRight(name, "-")
+	name = strings.TrimLeftFunc(name, func(r rune) bool {
+		return !('a' <= r && r <= 'z')
+	})
+	name = squeeze(name, func(r rune) bool { return r == '-' })
+	if 0 <= maxSize && maxSize < len(name) {
+		name = name[:maxSize]
+	}
+	return name
+}
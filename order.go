@@ -0,0 +1,163 @@
+package ycat
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// keyOrder records the order of object keys in a value
+type keyOrder struct {
+	index  map[string]int
+	fields map[string]*keyOrder
+	items  []*keyOrder
+}
+
+func newKeyOrder(x interface{}) *keyOrder {
+	switch x := x.(type) {
+	case Map:
+		k := keyOrder{
+			index:  make(map[string]int, len(x)),
+			fields: make(map[string]*keyOrder),
+		}
+		for i := range x {
+			key, ok := x[i].Key.(string)
+			if !ok {
+				continue
+			}
+			k.index[key] = i
+			if sub := newKeyOrder(x[i].Value); sub != nil {
+				k.fields[key] = sub
+			}
+		}
+		return &k
+	case []interface{}:
+		k := keyOrder{
+			items: make([]*keyOrder, len(x)),
+		}
+		hasOrder := false
+		for i, v := range x {
+			if sub := newKeyOrder(v); sub != nil {
+				k.items[i] = sub
+				hasOrder = true
+			}
+		}
+		if hasOrder {
+			return &k
+		}
+	}
+	return nil
+}
+
+// apply reorders keys of x in place.
+// Keys not found in the recorded order are appended in their original order.
+func (k *keyOrder) apply(x interface{}) {
+	if k == nil {
+		return
+	}
+	switch x := x.(type) {
+	case Map:
+		if len(k.index) > 0 {
+			rank := func(i int) int {
+				key, _ := x[i].Key.(string)
+				if n, ok := k.index[key]; ok {
+					return n
+				}
+				return len(k.index)
+			}
+			sort.SliceStable(x, func(i, j int) bool {
+				return rank(i) < rank(j)
+			})
+		}
+		for i := range x {
+			key, _ := x[i].Key.(string)
+			k.fields[key].apply(x[i].Value)
+		}
+	case []interface{}:
+		for i, v := range x {
+			if i < len(k.items) {
+				k.items[i].apply(v)
+			}
+		}
+	}
+}
+
+func decodeRawValue(v RawValue) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(string(v)))
+	dec.UseNumber()
+	return decodeValue(dec)
+}
+
+// encodeRawValue encodes a decoded value to compact JSON without escaping HTML characters
+func encodeRawValue(x interface{}) (RawValue, error) {
+	w := strings.Builder{}
+	enc := json.NewEncoder(&w)
+	enc.SetEscapeHTML(false)
+	if err := writeJSON(enc, &w, x); err != nil {
+		return "", err
+	}
+	// json.Encoder appends a new line after each value
+	return RawValue(w.String()).Compact()
+}
+
+// OrderKeys restores the key order of src on v.
+// Object keys that exist in src are placed in the same order,
+// other keys are appended in the order they appear in v.
+func (v RawValue) OrderKeys(src RawValue) (RawValue, error) {
+	switch src.Kind() {
+	case Object, Array:
+	default:
+		return v, nil
+	}
+	switch v.Kind() {
+	case Object, Array:
+	default:
+		return v, nil
+	}
+	x, err := decodeRawValue(src)
+	if err != nil {
+		return v, err
+	}
+	order := newKeyOrder(x)
+	if order == nil {
+		return v, nil
+	}
+	if x, err = decodeRawValue(v); err != nil {
+		return v, err
+	}
+	order.apply(x)
+	return encodeRawValue(x)
+}
+
+func sortKeys(x interface{}) {
+	switch x := x.(type) {
+	case Map:
+		sort.SliceStable(x, func(i, j int) bool {
+			ki, _ := x[i].Key.(string)
+			kj, _ := x[j].Key.(string)
+			return ki < kj
+		})
+		for i := range x {
+			sortKeys(x[i].Value)
+		}
+	case []interface{}:
+		for _, v := range x {
+			sortKeys(v)
+		}
+	}
+}
+
+// SortKeys sorts object keys of a value
+func (v RawValue) SortKeys() (RawValue, error) {
+	switch v.Kind() {
+	case Object, Array:
+	default:
+		return v, nil
+	}
+	x, err := decodeRawValue(v)
+	if err != nil {
+		return v, err
+	}
+	sortKeys(x)
+	return encodeRawValue(x)
+}
@@ -7,7 +7,7 @@ import (
 
 // Pipeline is the endpoint of a value stream process
 type Pipeline struct {
-	values <-chan RawValue
+	values <-chan Value
 	errors <-chan error
 }
 
@@ -23,8 +23,20 @@ func (p *Pipeline) Errors() <-chan error {
 
 // Values returns a channel with values from tasks
 func (p *Pipeline) Values() <-chan RawValue {
+	values := make(chan RawValue)
+	go func() {
+		defer close(values)
+		for v := range p.items() {
+			values <- v.RawValue
+		}
+	}()
+	return values
+}
+
+// items returns a channel with values and their metadata from tasks
+func (p *Pipeline) items() <-chan Value {
 	if p.values == nil {
-		ch := make(chan RawValue)
+		ch := make(chan Value)
 		close(ch)
 		p.values = ch
 	}
@@ -45,20 +57,20 @@ func (p *Pipeline) Pipe(ctx context.Context, tasks ...StreamTask) *Pipeline {
 		p = p.task(ctx, t)
 		ecs = append(ecs, p.Errors())
 	}
-	return &Pipeline{p.Values(), MergeErrors(ecs...)}
+	return &Pipeline{p.items(), MergeErrors(ecs...)}
 }
 
 func (p *Pipeline) task(ctx context.Context, task StreamTask) *Pipeline {
-	src := p.Values()
+	src := p.items()
 	errc := make(chan error, 1)
 	s := stream{
 		done: ctx.Done(),
 		src:  src,
 	}
-	var out chan RawValue
+	var out chan Value
 	switch task := task.(type) {
 	case Consumer:
-		out = make(chan RawValue)
+		out = make(chan Value)
 		close(out)
 		s.out = out
 		go func() {
@@ -69,16 +81,18 @@ func (p *Pipeline) task(ctx context.Context, task StreamTask) *Pipeline {
 			}
 		}()
 	case Producer:
-		out = make(chan RawValue, 1)
+		out = make(chan Value, 1)
 		s.out = out
 		go func() {
 			defer close(errc)
 			defer close(out)
 			Drain(&s)
+			// Produced values should not inherit metadata from drained values
+			s.meta = nil
 			errc <- task.Produce(&s)
 		}()
 	default:
-		out = make(chan RawValue)
+		out = make(chan Value)
 		s.out = out
 		go func() {
 			defer close(errc)
@@ -0,0 +1,338 @@
+package ycat
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	jsonnet "github.com/google/go-jsonnet"
+)
+
+// SortKey computes a key to sort a value with metadata by
+type SortKey func(v RawValue, m *Meta) (interface{}, error)
+
+// SortOrder is a sort key and its direction
+type SortOrder struct {
+	Key     SortKey
+	Reverse bool
+}
+
+// Sort is a StreamTask that buffers all values and pushes them ordered by keys.
+// Values with equal keys keep their order.
+type Sort struct {
+	Keys []SortOrder
+	// Errors is used to skip values that fail to compute a key
+	Errors *ErrorLog
+}
+
+// Run implements StreamTask
+func (o *Sort) Run(s Stream) error {
+	type item struct {
+		Value
+		keys []interface{}
+	}
+	var items []item
+next:
+	for {
+		v, ok := s.Next()
+		if !ok {
+			break
+		}
+		it := item{Value: Value{v, MetaOf(s)}}
+		for _, order := range o.Keys {
+			k, err := order.Key(v, it.Meta)
+			if err != nil {
+				err = newEvalError(err, v, it.Meta, 0)
+				if o.Errors == nil {
+					return err
+				}
+				o.Errors.Report(err)
+				continue next
+			}
+			it.keys = append(it.keys, k)
+		}
+		items = append(items, it)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		for k, order := range o.Keys {
+			c := compareValues(items[i].keys[k], items[j].keys[k])
+			if order.Reverse {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	for _, it := range items {
+		if !PushMeta(s, it.RawValue, it.Meta) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// valueRank orders values by type as jq does
+func valueRank(x interface{}) int {
+	switch x.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case json.Number:
+		return 2
+	case string:
+		return 3
+	case []interface{}:
+		return 4
+	default:
+		return 5
+	}
+}
+
+// compareValues compares decoded values
+func compareValues(a, b interface{}) int {
+	if ra, rb := valueRank(a), valueRank(b); ra != rb {
+		return ra - rb
+	}
+	switch a := a.(type) {
+	case bool:
+		switch b := b.(bool); {
+		case a == b:
+			return 0
+		case b:
+			return -1
+		default:
+			return 1
+		}
+	case json.Number:
+		fa, _ := a.Float64()
+		fb, _ := b.(json.Number).Float64()
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		default:
+			return 0
+		}
+	case string:
+		return strings.Compare(a, b.(string))
+	case []interface{}:
+		b := b.([]interface{})
+		for i := 0; i < len(a) && i < len(b); i++ {
+			if c := compareValues(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+		return len(a) - len(b)
+	case Map:
+		// Objects are compared by their sorted keys and then by the values of each key
+		ia, ib := sortedItems(a), sortedItems(b.(Map))
+		for i := 0; i < len(ia) && i < len(ib); i++ {
+			if c := strings.Compare(ia[i].Key.(string), ib[i].Key.(string)); c != 0 {
+				return c
+			}
+		}
+		if len(ia) != len(ib) {
+			return len(ia) - len(ib)
+		}
+		for i := range ia {
+			if c := compareValues(ia[i].Value, ib[i].Value); c != 0 {
+				return c
+			}
+		}
+		return 0
+	default:
+		return 0
+	}
+}
+
+// sortedItems returns a copy of an object with sorted keys
+func sortedItems(m Map) Map {
+	items := make(Map, len(m))
+	copy(items, m)
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Key.(string) < items[j].Key.(string)
+	})
+	return items
+}
+
+// NewSortKey creates a SortKey from an expression.
+// Expressions starting with $ or . are JSONPath, @k8s is the Kubernetes install order
+// and other expressions are Jsonnet snippets evaluated with e.
+func NewSortKey(expr string, e *Eval) (SortKey, error) {
+	switch {
+	case expr == "@k8s":
+		return K8sInstallOrder, nil
+	case strings.HasPrefix(expr, "$"), strings.HasPrefix(expr, "."):
+		return JSONPathKey(expr)
+	default:
+		if e == nil {
+			e = &Eval{}
+		}
+		return e.SortKey(expr), nil
+	}
+}
+
+// SortKey creates a SortKey evaluating a Jsonnet snippet for each value.
+// The VM is set up on first use so that options after the snippet apply.
+func (e *Eval) SortKey(snippet string) SortKey {
+	var (
+		vm       *jsonnet.VM
+		rendered string
+	)
+	return func(v RawValue, m *Meta) (interface{}, error) {
+		if vm == nil {
+			vm = jsonnet.MakeVM()
+			e.setup(vm)
+			rendered = e.Render(snippet)
+		}
+		result, err := e.evaluate(vm, "<sort-by>", rendered, v, m, false)
+		if err != nil {
+			return nil, err
+		}
+		return decodeRawValue(result)
+	}
+}
+
+// JSONPathKey creates a SortKey from a JSONPath expression.
+// Only child member and index selectors are supported, missing paths are null.
+func JSONPathKey(expr string) (SortKey, error) {
+	path, err := parseJSONPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return func(v RawValue, _ *Meta) (interface{}, error) {
+		x, err := decodeRawValue(v)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range path {
+			switch y := x.(type) {
+			case Map:
+				x = nil
+				for i := range y {
+					if y[i].Key == p {
+						x = y[i].Value
+						break
+					}
+				}
+			case []interface{}:
+				x = nil
+				if n, ok := p.(int); ok {
+					if n < 0 {
+						n += len(y)
+					}
+					if 0 <= n && n < len(y) {
+						x = y[n]
+					}
+				}
+			default:
+				return nil, nil
+			}
+		}
+		return x, nil
+	}, nil
+}
+
+// parseJSONPath parses a JSONPath to a list of string keys and int indexes
+func parseJSONPath(expr string) (path []interface{}, err error) {
+	s := strings.TrimPrefix(expr, "$")
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			n := strings.IndexAny(s, ".[")
+			if n == -1 {
+				n = len(s)
+			}
+			if n == 0 {
+				return nil, fmt.Errorf("Invalid JSONPath %q", expr)
+			}
+			path = append(path, s[:n])
+			s = s[n:]
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("Invalid JSONPath %q", expr)
+			}
+			sel := s[1:end]
+			s = s[end+1:]
+			if n, err := strconv.Atoi(sel); err == nil {
+				path = append(path, n)
+				continue
+			}
+			if len(sel) >= 2 && (sel[0] == '\'' || sel[0] == '"') && sel[len(sel)-1] == sel[0] {
+				path = append(path, sel[1:len(sel)-1])
+				continue
+			}
+			return nil, fmt.Errorf("Invalid JSONPath selector %q", sel)
+		default:
+			return nil, fmt.Errorf("Invalid JSONPath %q", expr)
+		}
+	}
+	return path, nil
+}
+
+// k8sInstallOrder is the order to install Kubernetes resources by kind
+var k8sInstallOrder = []string{
+	"PriorityClass",
+	"Namespace",
+	"NetworkPolicy",
+	"ResourceQuota",
+	"LimitRange",
+	"PodSecurityPolicy",
+	"PodDisruptionBudget",
+	"ServiceAccount",
+	"Secret",
+	"SecretList",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"CustomResourceDefinition",
+	"ClusterRole",
+	"ClusterRoleList",
+	"ClusterRoleBinding",
+	"ClusterRoleBindingList",
+	"Role",
+	"RoleList",
+	"RoleBinding",
+	"RoleBindingList",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"HorizontalPodAutoscaler",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"IngressClass",
+	"Ingress",
+	"APIService",
+}
+
+var kindKey, _ = JSONPathKey(".kind")
+
+// K8sInstallOrder is a SortKey ordering Kubernetes resources by kind so that they can be installed in order.
+// Unknown kinds are placed last.
+func K8sInstallOrder(v RawValue, m *Meta) (interface{}, error) {
+	kind, err := kindKey(v, m)
+	if err != nil {
+		return nil, err
+	}
+	rank := len(k8sInstallOrder)
+	for i, k := range k8sInstallOrder {
+		if k == kind {
+			rank = i
+			break
+		}
+	}
+	return json.Number(strconv.Itoa(rank)), nil
+}
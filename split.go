@@ -0,0 +1,138 @@
+package ycat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	jsonnet "github.com/google/go-jsonnet"
+)
+
+// SplitOutput is a Consumer that writes each value to a file in a directory.
+// File names are computed from the values with a Jsonnet expression.
+type SplitOutput struct {
+	Dir string
+	// Name is a Jsonnet expression for the file name of a value
+	Name string
+	// Append writes values with the same file name to a multi-document file
+	Append bool
+	// Output is the output format for files with unknown extensions
+	Output Output
+	JSON   JSONOptions
+	CSV    *CSVOptions
+	// Eval is the environment for the name expression
+	Eval *Eval
+}
+
+// Consume implements Consumer.
+// All values are buffered to detect file name collisions before writing any files.
+func (o *SplitOutput) Consume(s ReadStream) error {
+	if o.Name == "" {
+		return errors.New("Missing output name expression")
+	}
+	e := o.Eval
+	if e == nil {
+		e = &Eval{}
+	}
+	vm := jsonnet.MakeVM()
+	e.setup(vm)
+	snippet := e.Render(o.Name)
+	var (
+		names []string
+		files = make(map[string][]Value)
+	)
+	for {
+		v, ok := s.Next()
+		if !ok {
+			break
+		}
+		name, err := o.fileName(e, vm, snippet, v, MetaOf(s))
+		if err != nil {
+			return newEvalError(err, v, MetaOf(s), e.DumpSize)
+		}
+		values, exists := files[name]
+		if exists && !o.Append {
+			return fmt.Errorf("Output file collision: %q", name)
+		}
+		if !exists {
+			names = append(names, name)
+		}
+		files[name] = append(values, Value{v, MetaOf(s)})
+	}
+	for _, name := range names {
+		if err := o.writeFile(name, files[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileName evaluates the name expression for a value
+func (o *SplitOutput) fileName(e *Eval, vm *jsonnet.VM, snippet string, v RawValue, meta *Meta) (string, error) {
+	result, err := e.evaluate(vm, "<output-name>", snippet, v, meta, false)
+	if err != nil {
+		return "", err
+	}
+	var name string
+	if err := json.Unmarshal([]byte(result), &name); err != nil {
+		return "", fmt.Errorf("Invalid output name %s, must be a string", result)
+	}
+	name = path.Clean("/" + name)[1:]
+	if name == "" {
+		return "", errors.New("Empty output name")
+	}
+	return name, nil
+}
+
+func (o *SplitOutput) writeFile(name string, values []Value) error {
+	filename := filepath.Join(o.Dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	output := o.Output
+	switch strings.ToLower(path.Ext(name)) {
+	case ".json":
+		output = OutputJSON
+	case ".yaml", ".yml":
+		output = OutputYAML
+	case ".toml":
+		output = OutputTOML
+	case ".csv":
+		output = OutputCSV
+	case ".tsv":
+		output = OutputTSV
+	case ".txt":
+		output = OutputRaw
+	}
+	return StreamWrite(f, output, o.JSON, o.CSV)(&valueStream{values: values})
+}
+
+// valueStream is a ReadStream of buffered values
+type valueStream struct {
+	values []Value
+	meta   *Meta
+}
+
+// Next implements ReadStream
+func (s *valueStream) Next() (RawValue, bool) {
+	if len(s.values) == 0 {
+		return "", false
+	}
+	v := s.values[0]
+	s.values = s.values[1:]
+	s.meta = v.Meta
+	return v.RawValue, true
+}
+
+// Meta implements MetaReader
+func (s *valueStream) Meta() *Meta {
+	return s.meta
+}
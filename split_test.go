@@ -0,0 +1,47 @@
+package ycat_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitOutput(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.yaml")
+	data := "kind: Foo\nname: a\n---\n# bar\nkind: Bar\nname: b\n---\nkind: Foo\nname: c\n"
+	if err := ioutil.WriteFile(in, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+	if _, err := runArgs(t, in, "--output-dir", out, "--output-name", `x.kind + "/" + x.name + ".yaml"`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runArgs(t, in, "--output-dir", out, "--output-name", `std.asciiLower(x.kind) + ".json"`, "--append"); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"Foo/a.yaml": "kind: Foo\nname: a\n",
+		"Bar/b.yaml": "# bar\nkind: Bar\nname: b\n",
+		"Foo/c.yaml": "kind: Foo\nname: c\n",
+		"foo.json":   `{"kind":"Foo","name":"a"}` + "\n" + `{"kind":"Foo","name":"c"}` + "\n",
+		"bar.json":   `{"kind":"Bar","name":"b"}` + "\n",
+	}
+	for name, data := range want {
+		got, err := ioutil.ReadFile(filepath.Join(out, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != data {
+			t.Errorf("Wrong %s contents: %q != %q", name, got, data)
+		}
+	}
+	_, err := runArgs(t, in, "--output-dir", filepath.Join(dir, "collision"), "--output-name", `x.kind + ".yaml"`)
+	if err == nil || !strings.Contains(err.Error(), "collision") {
+		t.Errorf("Collision error = %v", err)
+	}
+	if _, err := ioutil.ReadDir(filepath.Join(dir, "collision")); err == nil {
+		t.Errorf("Files written on collision")
+	}
+}
@@ -81,16 +81,66 @@ func (tasks Producers) Produce(s WriteStream) error {
 	return nil
 }
 
+// Value is a stream value along with its metadata
+type Value struct {
+	RawValue
+	Meta *Meta
+}
+
+// Meta holds optional information about a stream value
+type Meta struct {
+	// Filename is the path of the input file, empty for stdin
+	Filename string
+	// Document is the number of the value in the input starting at 1
+	Document int
+	// Format is the input format
+	Format Format
+	// Line is the line where the value starts in the input, 0 if unknown
+	Line int
+	// Comments are the YAML comments of the value
+	Comments *Comments
+}
+
+// MetaReader is a readable stream that tracks value metadata
+type MetaReader interface {
+	// Meta returns the metadata of the last value returned by Next
+	Meta() *Meta
+}
+
+// MetaWriter is a writable stream that accepts value metadata
+type MetaWriter interface {
+	PushMeta(v RawValue, m *Meta) bool
+}
+
+// MetaOf returns the metadata of the last value read from a stream
+func MetaOf(s ReadStream) *Meta {
+	if s, ok := s.(MetaReader); ok {
+		return s.Meta()
+	}
+	return nil
+}
+
+// PushMeta pushes a value with metadata to a stream.
+// Metadata is discarded if the stream does not implement MetaWriter.
+func PushMeta(s WriteStream, v RawValue, m *Meta) bool {
+	if s, ok := s.(MetaWriter); ok {
+		return s.PushMeta(v, m)
+	}
+	return s.Push(v)
+}
+
 type stream struct {
 	done <-chan struct{}
-	src  <-chan RawValue
-	out  chan<- RawValue
+	src  <-chan Value
+	out  chan<- Value
+	meta *Meta
 }
 
 // Next implements ReadStream
 func (s *stream) Next() (v RawValue, ok bool) {
 	select {
-	case v, ok = <-s.src:
+	case item, more := <-s.src:
+		v, ok, s.meta = item.RawValue, more, item.Meta
 		// println("s.value", ok)
 	case <-s.done:
 		// println("next s.done", ok)
@@ -98,10 +148,21 @@ func (s *stream) Next() (v RawValue, ok bool) {
 	return
 }
 
-// Push implements WriteStream
+// Meta implements MetaReader
+func (s *stream) Meta() *Meta {
+	return s.meta
+}
+
+// Push implements WriteStream.
+// The value inherits the metadata of the last value read from the stream.
 func (s *stream) Push(v RawValue) bool {
+	return s.PushMeta(v, s.meta)
+}
+
+// PushMeta implements MetaWriter
+func (s *stream) PushMeta(v RawValue, m *Meta) bool {
 	select {
-	case s.out <- v:
+	case s.out <- Value{v, m}:
 		return true
 	case <-s.done:
 		return false
@@ -113,7 +174,7 @@ type NullStream struct{}
 
 // Produce implements Producer for NullStream
 func (NullStream) Produce(s WriteStream) error {
-	s.Push("null")
+	PushMeta(s, "null", nil)
 	return nil
 }
 
@@ -150,11 +211,72 @@ func (ToArray) Run(s Stream) (err error) {
 		}
 	}
 	if values != nil {
-		s.Push(RawValueArray(values...))
+		PushMeta(s, RawValueArray(values...), nil)
 	}
 	return
 }
 
+// Spread splits array values to separate values, the inverse of ToArray
+type Spread struct{}
+
+// Run implements StreamTask for Spread
+func (Spread) Run(s Stream) error {
+	for {
+		v, ok := s.Next()
+		if !ok {
+			return nil
+		}
+		if ok, err := spreadValue(s, v, MetaOf(s)); !ok || err != nil {
+			return err
+		}
+	}
+}
+
+// spreadValue pushes the items of an array value or the value itself if it is not an array
+func spreadValue(s WriteStream, v RawValue, m *Meta) (bool, error) {
+	if v.Kind() != Array {
+		return PushMeta(s, v, m), nil
+	}
+	x, err := decodeRawValue(v)
+	if err != nil {
+		return false, err
+	}
+	// Comments of the array do not apply to the items
+	var meta *Meta
+	if m != nil {
+		meta = &Meta{Filename: m.Filename, Document: m.Document, Format: m.Format, Line: m.Line}
+	}
+	for _, item := range x.([]interface{}) {
+		v, err := encodeRawValue(item)
+		if err != nil {
+			return false, err
+		}
+		if !PushMeta(s, v, meta) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// DropNull removes null values from a stream
+type DropNull struct{}
+
+// Run implements StreamTask for DropNull
+func (DropNull) Run(s Stream) error {
+	for {
+		v, ok := s.Next()
+		if !ok {
+			return nil
+		}
+		if v.Kind() == Null {
+			continue
+		}
+		if !s.Push(v) {
+			return nil
+		}
+	}
+}
+
 // Drain is a helper that drains all values from a stream
 func Drain(s Stream) bool {
 	for {
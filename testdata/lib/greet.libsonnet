@@ -0,0 +1,3 @@
+{
+    greet(name):: "hello " + name,
+}
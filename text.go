@@ -0,0 +1,94 @@
+package ycat
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// TextOptions are options for text input
+type TextOptions struct {
+	// KeepNewlines keeps the line separator at the end of values
+	KeepNewlines bool
+	// Raw reads the whole input as a single string
+	Raw bool
+	// NullData separates lines with NUL instead of newline
+	NullData bool
+}
+
+// Decoder returns a DecoderFunc for text input
+func (o *TextOptions) Decoder() DecoderFunc {
+	return func(r io.Reader) Decoder {
+		return NewTextDecoder(r, *o)
+	}
+}
+
+type textDecoder struct {
+	r       *bufio.Reader
+	options TextOptions
+	done    bool
+	// Newlines read before the last value
+	lines int
+	next  int
+}
+
+// Line implements lineDecoder
+func (d *textDecoder) Line() int {
+	return d.lines + 1
+}
+
+// NewTextDecoder creates a Decoder reading each line of text input as a string value
+func NewTextDecoder(r io.Reader, options TextOptions) Decoder {
+	return &textDecoder{
+		r:       bufio.NewReader(r),
+		options: options,
+	}
+}
+
+// Decode implements Decoder
+func (d *textDecoder) Decode(x interface{}) error {
+	if d.done {
+		return io.EOF
+	}
+	d.lines = d.next
+	var line string
+	if d.options.Raw {
+		d.done = true
+		data, err := ioutil.ReadAll(d.r)
+		if err != nil {
+			return err
+		}
+		line = string(data)
+	} else {
+		sep := byte('\n')
+		if d.options.NullData {
+			sep = 0
+		}
+		var err error
+		line, err = d.r.ReadString(sep)
+		d.next += strings.Count(line, "\n")
+		switch {
+		case err == io.EOF && line != "":
+			// Last line without a separator
+			d.done = true
+		case err != nil:
+			return err
+		case !d.options.KeepNewlines:
+			line = line[:len(line)-1]
+			if sep == '\n' {
+				line = strings.TrimSuffix(line, "\r")
+			}
+		}
+	}
+	v, err := encodeRawValue(line)
+	if err != nil {
+		return err
+	}
+	if raw, ok := x.(*RawValue); ok {
+		*raw = v
+		return nil
+	}
+	return json.Unmarshal([]byte(v), x)
+}
@@ -0,0 +1,362 @@
+package ycat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// tomlDecoder decodes a TOML document
+type tomlDecoder struct {
+	r    io.Reader
+	done bool
+}
+
+// Line implements lineDecoder, a TOML document starts at the first line
+func (d *tomlDecoder) Line() int {
+	return 1
+}
+
+// Decode implements Decoder.
+// A TOML input is a single document, subsequent calls return io.EOF
+func (d *tomlDecoder) Decode(x interface{}) error {
+	if d.done {
+		return io.EOF
+	}
+	d.done = true
+	v, ok := x.(*RawValue)
+	if !ok {
+		_, err := toml.NewDecoder(d.r).Decode(x)
+		return err
+	}
+	var m map[string]interface{}
+	md, err := toml.NewDecoder(d.r).Decode(&m)
+	if err != nil {
+		return err
+	}
+	order := tomlKeyOrder{}
+	for _, key := range md.Keys() {
+		order.add(key)
+	}
+	*v, err = encodeRawValue(order.value(m))
+	return err
+}
+
+// tomlKeyOrder records the order of keys in a TOML document
+type tomlKeyOrder struct {
+	index  map[string]int
+	fields map[string]*tomlKeyOrder
+}
+
+func (k *tomlKeyOrder) add(key []string) {
+	for _, name := range key {
+		if k.index == nil {
+			k.index = make(map[string]int)
+			k.fields = make(map[string]*tomlKeyOrder)
+		}
+		if _, ok := k.index[name]; !ok {
+			k.index[name] = len(k.index)
+			k.fields[name] = &tomlKeyOrder{}
+		}
+		k = k.fields[name]
+	}
+}
+
+// value converts a decoded TOML value to an ordered value
+func (k *tomlKeyOrder) value(x interface{}) interface{} {
+	switch x := x.(type) {
+	case map[string]interface{}:
+		m := make(Map, 0, len(x))
+		for key, v := range x {
+			var sub *tomlKeyOrder
+			if k != nil {
+				sub = k.fields[key]
+			}
+			m = append(m, yaml.MapItem{Key: key, Value: sub.value(v)})
+		}
+		rank := func(i int) int {
+			if k != nil {
+				if n, ok := k.index[m[i].Key.(string)]; ok {
+					return n
+				}
+			}
+			return math.MaxInt32
+		}
+		sort.Slice(m, func(i, j int) bool {
+			if ri, rj := rank(i), rank(j); ri != rj {
+				return ri < rj
+			}
+			return m[i].Key.(string) < m[j].Key.(string)
+		})
+		return m
+	case []map[string]interface{}:
+		arr := make([]interface{}, len(x))
+		for i, v := range x {
+			arr[i] = k.value(v)
+		}
+		return arr
+	case []interface{}:
+		arr := make([]interface{}, len(x))
+		for i, v := range x {
+			arr[i] = k.value(v)
+		}
+		return arr
+	case time.Time:
+		switch x.Location().String() {
+		case "datetime-local":
+			return x.Format("2006-01-02T15:04:05.999999999")
+		case "date-local":
+			return x.Format("2006-01-02")
+		case "time-local":
+			return x.Format("15:04:05.999999999")
+		default:
+			return x.Format(time.RFC3339Nano)
+		}
+	default:
+		return x
+	}
+}
+
+// StreamWriteTOML creates a StreamTask to write a value as TOML to a Writer.
+// TOML documents cannot be concatenated so the stream must contain a single object.
+func StreamWriteTOML(w io.WriteCloser) ConsumerFunc {
+	return func(s ReadStream) error {
+		defer w.Close()
+		for numValues := 0; ; numValues++ {
+			v, ok := s.Next()
+			if !ok {
+				return nil
+			}
+			if numValues > 0 {
+				return fmt.Errorf("toml: cannot write multiple values to a TOML document")
+			}
+			data, err := MarshalTOML(v)
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// MarshalTOML converts an object value to a TOML document keeping key order
+func MarshalTOML(v RawValue) ([]byte, error) {
+	if kind := v.Kind(); kind != Object {
+		return nil, fmt.Errorf("toml: cannot write %s value, a TOML document must be an object", kind)
+	}
+	x, err := decodeRawValue(v)
+	if err != nil {
+		return nil, err
+	}
+	enc := tomlEncoder{}
+	if err := enc.table(nil, x.(Map)); err != nil {
+		return nil, err
+	}
+	return enc.Bytes(), nil
+}
+
+type tomlEncoder struct {
+	bytes.Buffer
+}
+
+func isTable(x interface{}) bool {
+	_, ok := x.(Map)
+	return ok
+}
+
+func isTableArray(x interface{}) bool {
+	arr, ok := x.([]interface{})
+	if !ok || len(arr) == 0 {
+		return false
+	}
+	for _, el := range arr {
+		if !isTable(el) {
+			return false
+		}
+	}
+	return true
+}
+
+func (enc *tomlEncoder) header(path []string, array bool) {
+	if enc.Len() > 0 {
+		enc.WriteByte('\n')
+	}
+	if array {
+		enc.WriteString("[[")
+	} else {
+		enc.WriteByte('[')
+	}
+	for i, key := range path {
+		if i > 0 {
+			enc.WriteByte('.')
+		}
+		enc.key(key)
+	}
+	if array {
+		enc.WriteString("]]")
+	} else {
+		enc.WriteByte(']')
+	}
+	enc.WriteByte('\n')
+}
+
+func (enc *tomlEncoder) table(path []string, m Map) error {
+	// Key/value pairs must come before sub tables
+	for i := range m {
+		item := &m[i]
+		if isTable(item.Value) || isTableArray(item.Value) {
+			continue
+		}
+		key := item.Key.(string)
+		enc.key(key)
+		enc.WriteString(" = ")
+		if err := enc.value(append(path, key), item.Value); err != nil {
+			return err
+		}
+		enc.WriteByte('\n')
+	}
+	for i := range m {
+		item := &m[i]
+		p := append(path[:len(path):len(path)], item.Key.(string))
+		switch x := item.Value.(type) {
+		case Map:
+			if hasValues(x) || len(x) == 0 {
+				enc.header(p, false)
+			}
+			if err := enc.table(p, x); err != nil {
+				return err
+			}
+		case []interface{}:
+			if !isTableArray(x) {
+				continue
+			}
+			for _, el := range x {
+				enc.header(p, true)
+				if err := enc.table(p, el.(Map)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// hasValues checks if a table has key/value pairs that require a header
+func hasValues(m Map) bool {
+	for i := range m {
+		if v := m[i].Value; !isTable(v) && !isTableArray(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func isBareKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, c := range key {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '_', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (enc *tomlEncoder) key(key string) {
+	if isBareKey(key) {
+		enc.WriteString(key)
+	} else {
+		enc.str(key)
+	}
+}
+
+func (enc *tomlEncoder) str(s string) {
+	enc.WriteByte('"')
+	for _, c := range s {
+		switch c {
+		case '"':
+			enc.WriteString(`\"`)
+		case '\\':
+			enc.WriteString(`\\`)
+		case '\b':
+			enc.WriteString(`\b`)
+		case '\t':
+			enc.WriteString(`\t`)
+		case '\n':
+			enc.WriteString(`\n`)
+		case '\f':
+			enc.WriteString(`\f`)
+		case '\r':
+			enc.WriteString(`\r`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				fmt.Fprintf(enc, `\u%04X`, c)
+			} else {
+				enc.WriteRune(c)
+			}
+		}
+	}
+	enc.WriteByte('"')
+}
+
+func (enc *tomlEncoder) value(path []string, x interface{}) error {
+	switch x := x.(type) {
+	case nil:
+		return fmt.Errorf("toml: cannot write null value of %q", strings.Join(path, "."))
+	case string:
+		enc.str(x)
+	case bool:
+		enc.WriteString(strconv.FormatBool(x))
+	case json.Number:
+		s := x.String()
+		if !strings.ContainsAny(s, ".eE") {
+			if _, err := x.Int64(); err != nil {
+				return fmt.Errorf("toml: integer %s of %q is out of range", s, strings.Join(path, "."))
+			}
+		}
+		enc.WriteString(s)
+	case []interface{}:
+		enc.WriteByte('[')
+		for i, el := range x {
+			if i > 0 {
+				enc.WriteString(", ")
+			}
+			if err := enc.value(append(path, strconv.Itoa(i)), el); err != nil {
+				return err
+			}
+		}
+		enc.WriteByte(']')
+	case Map:
+		// Inline table
+		enc.WriteByte('{')
+		for i := range x {
+			if i > 0 {
+				enc.WriteString(", ")
+			}
+			key := x[i].Key.(string)
+			enc.key(key)
+			enc.WriteString(" = ")
+			if err := enc.value(append(path, key), x[i].Value); err != nil {
+				return err
+			}
+		}
+		enc.WriteByte('}')
+	default:
+		return fmt.Errorf("toml: invalid value %v of %q", x, strings.Join(path, "."))
+	}
+	return nil
+}
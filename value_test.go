@@ -138,3 +138,29 @@ func TestRawValue_MarshalYAML(t *testing.T) {
 		})
 	}
 }
+
+func TestRawValue_OrderKeys(t *testing.T) {
+	tests := []struct {
+		value ycat.RawValue
+		src   ycat.RawValue
+		want  ycat.RawValue
+	}{
+		{`{"a":1,"b":2}`, `{"b":0,"a":0}`, `{"b":2,"a":1}`},
+		{`{"a":1,"b":2,"c":3}`, `{"c":0}`, `{"c":3,"a":1,"b":2}`},
+		{`[{"a":1,"b":2}]`, `[{"b":0,"a":0}]`, `[{"b":2,"a":1}]`},
+		{`{"a":{"x":1,"y":2},"b":"<>"}`, `{"b":null,"a":{"y":0,"x":0}}`, `{"b":"<>","a":{"y":2,"x":1}}`},
+		{`{"a":1}`, `42`, `{"a":1}`},
+		{`42`, `{"a":1}`, `42`},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.want), func(t *testing.T) {
+			v, err := tt.value.OrderKeys(tt.src)
+			if err != nil {
+				t.Fatalf("RawValue.OrderKeys() error = %v", err)
+			}
+			if v, _ = v.Compact(); v != tt.want {
+				t.Errorf("RawValue.OrderKeys() %q != %q", v, tt.want)
+			}
+		})
+	}
+}
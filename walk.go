@@ -0,0 +1,189 @@
+package ycat
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Walk finds input files in directories
+type Walk struct {
+	// Include and Exclude are glob patterns for paths relative to the walked directory.
+	// A ** segment matches any number of directories and patterns without a slash match the base name.
+	Include []string
+	Exclude []string
+	// FollowSymlinks walks symbolic links, otherwise they are skipped
+	FollowSymlinks bool
+}
+
+// Files lists the files under root recursively in lexical order.
+// If root is a directory only files with an extension of format are listed, or of any data format if format is Auto.
+// If root is a glob pattern, files under its leading directory that match the pattern are listed regardless
+// of their extension, except for Jsonnet and jq scripts if format is Auto.
+func (w *Walk) Files(root string, format Format) ([]string, error) {
+	var pattern []string
+	if glob := filepath.ToSlash(root); isGlob(glob) {
+		root, pattern = splitGlob(glob)
+	}
+	var (
+		files []string
+		// Real paths of parent directories to detect symlink loops
+		parents = make(map[string]bool)
+		walk    func(dir, rel string) error
+	)
+	walk = func(dir, rel string) error {
+		if w.FollowSymlinks {
+			real, err := filepath.EvalSymlinks(dir)
+			if err != nil {
+				return err
+			}
+			if parents[real] {
+				return nil
+			}
+			parents[real] = true
+			defer delete(parents, real)
+		}
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, fi := range entries {
+			name := path.Join(rel, fi.Name())
+			filename := filepath.Join(dir, fi.Name())
+			if fi.Mode()&os.ModeSymlink != 0 {
+				if !w.FollowSymlinks {
+					continue
+				}
+				if fi, err = os.Stat(filename); err != nil {
+					return err
+				}
+			}
+			if matchAny(w.Exclude, name) {
+				continue
+			}
+			if fi.IsDir() {
+				if err := walk(filename, name); err != nil {
+					return err
+				}
+				continue
+			}
+			switch {
+			case !fi.Mode().IsRegular():
+			case pattern != nil && !matchSegments(pattern, strings.Split(name, "/")):
+			case len(w.Include) > 0 && !matchAny(w.Include, name):
+			case pattern == nil && !walkFormat(name, format):
+			case pattern != nil && format == Auto && isScript(name):
+			default:
+				files = append(files, filename)
+			}
+		}
+		return nil
+	}
+	if err := walk(root, ""); err != nil {
+		return nil, err
+	}
+	if pattern != nil && len(files) == 0 {
+		return nil, fmt.Errorf("No input files match %q", path.Join(filepath.ToSlash(root), path.Join(pattern...)))
+	}
+	return files, nil
+}
+
+// walkFormat checks if a file found by Walk should be read as format
+func walkFormat(filename string, format Format) bool {
+	switch format {
+	case Auto:
+		switch extFormat(filename) {
+		case JSON, YAML, TOML, CSV, TSV:
+			return true
+		default:
+			return false
+		}
+	case BASE64, HEX:
+		return true
+	default:
+		return extFormat(filename) == format
+	}
+}
+
+// isScript checks if a file is a Jsonnet or jq script
+func isScript(filename string) bool {
+	switch extFormat(filename) {
+	case JSONNET, JQ:
+		return true
+	default:
+		return false
+	}
+}
+
+// isWalkPath checks if a path is a directory or a glob pattern to list input files with Walk
+func isWalkPath(p string) bool {
+	info, err := os.Stat(p)
+	if err != nil {
+		return os.IsNotExist(err) && isGlob(filepath.ToSlash(p))
+	}
+	return info.IsDir()
+}
+
+func isGlob(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
+
+// splitGlob splits a slash separated glob to the directory before the first pattern segment and the pattern segments
+func splitGlob(glob string) (string, []string) {
+	segments := strings.Split(glob, "/")
+	n := 0
+	for n < len(segments) && !isGlob(segments[n]) {
+		n++
+	}
+	dir := strings.Join(segments[:n], "/")
+	switch {
+	case dir == "" && n > 0:
+		dir = "/"
+	case dir == "":
+		dir = "."
+	}
+	return filepath.FromSlash(dir), segments[n:]
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches a slash separated path to a glob pattern
+func matchGlob(pattern, name string) bool {
+	pattern = strings.TrimPrefix(pattern, "/")
+	if !strings.Contains(pattern, "/") && pattern != "**" {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,68 @@
+package ycat_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"b.yaml":             "b\n",
+		"a/c.json":           `"c"`,
+		"a/d.test.yaml":      "d\n",
+		"a/NOTES.txt":        "notes\n",
+		"a/e/f.yml":          "f\n",
+		"vendor/g.yaml":      "g\n",
+		"a/script.jsonnet":   "x",
+		"other/h.yaml":       "h\n",
+		"other/deep/i.yaml":  "i\n",
+		"other/deep/j.toml":  "j = 1\n",
+		"other/deep/k.other": "k\n",
+	}
+	for name, data := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(dir, "other"), filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	// Symlink loop
+	if err := os.Symlink(dir, filepath.Join(dir, "other", "loop")); err != nil {
+		t.Fatal(err)
+	}
+	type TestCase struct {
+		Args   []string
+		Stdout string
+	}
+	for _, tc := range []TestCase{
+		{[]string{dir}, "c\n---\nd\n---\nf\n---\nb\n---\ni\n---\nj: 1\n---\nh\n---\ng\n"},
+		{[]string{dir, "--exclude", "vendor", "--exclude", "*.test.yaml", "--exclude", "other"}, "c\n---\nf\n---\nb\n"},
+		{[]string{"--include", "a/**", dir}, "c\n---\nd\n---\nf\n"},
+		{[]string{"--include", "**/deep/*", "--follow-symlinks", dir}, "i\n---\nj: 1\n---\ni\n---\nj: 1\n"},
+		{[]string{"-j", dir}, "c\n"},
+		{[]string{filepath.Join(dir, "a", "*.yaml")}, "d\n"},
+		{[]string{filepath.Join(dir, "**", "[fi].y*ml"), "-o", "j"}, `"f"` + "\n" + `"i"` + "\n"},
+		{[]string{"-y", filepath.Join(dir, "other", "**", "*.other")}, "k\n"},
+		{[]string{filepath.Join(dir, "a", "*")}, "notes\n---\nc\n---\nd\n"},
+	} {
+		out, err := runArgs(t, tc.Args...)
+		if err != nil {
+			t.Errorf("%v: %s", tc.Args, err)
+			continue
+		}
+		if out != tc.Stdout {
+			t.Errorf("%v: Wrong output: %q != %q", tc.Args, out, tc.Stdout)
+		}
+	}
+	if _, err := runArgs(t, filepath.Join(dir, "*.json")); err == nil {
+		t.Errorf("No error for glob without matches")
+	}
+}
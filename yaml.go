@@ -0,0 +1,372 @@
+package ycat
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	yamlv2 "gopkg.in/yaml.v2"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Comment is the set of comments attached to a YAML node
+type Comment struct {
+	Head string
+	Line string
+	Foot string
+}
+
+func nodeComment(n *yaml.Node) Comment {
+	return Comment{
+		Head: n.HeadComment,
+		Line: n.LineComment,
+		Foot: n.FootComment,
+	}
+}
+
+func (c Comment) attach(n *yaml.Node) {
+	n.HeadComment = c.Head
+	n.LineComment = c.Line
+	n.FootComment = c.Foot
+}
+
+// Comments holds the comments of a YAML document.
+// Comments are keyed by the JSON pointer of the value they belong to.
+type Comments struct {
+	// Document comments
+	Comment
+	// Keys are comments attached to object keys
+	Keys map[string]Comment
+	// Values are comments attached to values
+	Values map[string]Comment
+}
+
+func addComment(m map[string]Comment, ptr string, n *yaml.Node) map[string]Comment {
+	if n.HeadComment == "" && n.LineComment == "" && n.FootComment == "" {
+		return m
+	}
+	if m == nil {
+		m = make(map[string]Comment)
+	}
+	m[ptr] = nodeComment(n)
+	return m
+}
+
+// Empty checks if there are no comments
+func (c *Comments) Empty() bool {
+	return c == nil || (c.Comment == Comment{} && len(c.Keys) == 0 && len(c.Values) == 0)
+}
+
+var pointerEscape = strings.NewReplacer("~", "~0", "/", "~1")
+
+func pointerKey(ptr, key string) string {
+	return ptr + "/" + pointerEscape.Replace(key)
+}
+
+func pointerIndex(ptr string, i int) string {
+	return ptr + "/" + strconv.Itoa(i)
+}
+
+// yamlDecoder decodes YAML documents keeping comments
+type yamlDecoder struct {
+	dec  *yaml.Decoder
+	line int
+}
+
+func newYAMLDecoder(r io.Reader) *yamlDecoder {
+	return &yamlDecoder{dec: yaml.NewDecoder(r)}
+}
+
+// Line implements lineDecoder
+func (d *yamlDecoder) Line() int {
+	return d.line
+}
+
+// Decode implements Decoder
+func (d *yamlDecoder) Decode(x interface{}) error {
+	if v, ok := x.(*RawValue); ok {
+		raw, _, err := d.DecodeValue()
+		*v = raw
+		return err
+	}
+	return d.dec.Decode(x)
+}
+
+// DecodeValue decodes the next YAML document to a value
+func (d *yamlDecoder) DecodeValue() (RawValue, *Comments, error) {
+	var doc yaml.Node
+	if err := d.dec.Decode(&doc); err != nil {
+		return "", nil, err
+	}
+	d.line = doc.Line
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		d.line = doc.Content[0].Line
+	}
+	return decodeYAMLDocument(&doc)
+}
+
+func decodeYAMLDocument(doc *yaml.Node) (RawValue, *Comments, error) {
+	nd := nodeDecoder{
+		comments: &Comments{
+			Comment: nodeComment(doc),
+		},
+	}
+	var (
+		x   interface{}
+		err error
+	)
+	if doc.Kind == yaml.DocumentNode {
+		if len(doc.Content) > 0 {
+			x, err = nd.value(doc.Content[0], "")
+		}
+	} else {
+		nd.comments.Comment = Comment{}
+		x, err = nd.value(doc, "")
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	v, err := encodeRawValue(x)
+	if err != nil {
+		return "", nil, err
+	}
+	if nd.comments.Empty() {
+		return v, nil, nil
+	}
+	return v, nd.comments, nil
+}
+
+type nodeDecoder struct {
+	comments *Comments
+}
+
+func (d *nodeDecoder) value(n *yaml.Node, ptr string) (interface{}, error) {
+	if c := d.comments; c != nil {
+		c.Values = addComment(c.Values, ptr, n)
+	}
+	switch n.Kind {
+	case yaml.AliasNode:
+		// Do not duplicate comments of the anchored value
+		alias := nodeDecoder{}
+		return alias.value(n.Alias, ptr)
+	case yaml.MappingNode:
+		return d.mapping(n, ptr)
+	case yaml.SequenceNode:
+		arr := make([]interface{}, len(n.Content))
+		for i, el := range n.Content {
+			v, err := d.value(el, pointerIndex(ptr, i))
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = v
+		}
+		return arr, nil
+	case yaml.ScalarNode:
+		return scalarValue(n)
+	case yaml.DocumentNode:
+		if len(n.Content) > 0 {
+			return d.value(n.Content[0], ptr)
+		}
+		return nil, nil
+	default:
+		return nil, nodeError(n, "invalid node")
+	}
+}
+
+func (d *nodeDecoder) mapping(n *yaml.Node, ptr string) (interface{}, error) {
+	var (
+		m     = emptyMap()
+		index = make(map[string]int)
+	)
+	set := func(key string, value interface{}, merge bool) {
+		if i, ok := index[key]; ok {
+			if !merge {
+				m[i].Value = value
+			}
+			return
+		}
+		index[key] = len(m)
+		m = append(m, yamlv2.MapItem{Key: key, Value: value})
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		if k.Kind == yaml.ScalarNode && k.ShortTag() == "!!merge" {
+			merged, err := mergeMaps(v)
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range merged {
+				set(item.Key.(string), item.Value, true)
+			}
+			continue
+		}
+		if k.Kind != yaml.ScalarNode {
+			return nil, nodeError(k, "invalid object key")
+		}
+		key := k.Value
+		p := pointerKey(ptr, key)
+		if c := d.comments; c != nil {
+			c.Keys = addComment(c.Keys, p, k)
+		}
+		value, err := d.value(v, p)
+		if err != nil {
+			return nil, err
+		}
+		set(key, value, false)
+	}
+	return m, nil
+}
+
+func mergeMaps(n *yaml.Node) (Map, error) {
+	nd := nodeDecoder{}
+	switch n.Kind {
+	case yaml.SequenceNode:
+		var m Map
+		// Earlier maps take precedence
+		for i := len(n.Content) - 1; i >= 0; i-- {
+			x, err := mergeMaps(n.Content[i])
+			if err != nil {
+				return nil, err
+			}
+			m = append(x, m...)
+		}
+		return m, nil
+	default:
+		x, err := nd.value(n, "")
+		if err != nil {
+			return nil, err
+		}
+		if m, ok := x.(Map); ok {
+			return m, nil
+		}
+		return nil, nodeError(n, "merged value is not an object")
+	}
+}
+
+func scalarValue(n *yaml.Node) (interface{}, error) {
+	switch n.ShortTag() {
+	case "!!null":
+		return nil, nil
+	case "!!bool":
+		var b bool
+		if err := n.Decode(&b); err != nil {
+			return nil, err
+		}
+		return b, nil
+	case "!!int", "!!float":
+		if json.Valid([]byte(n.Value)) {
+			return json.Number(n.Value), nil
+		}
+		var x interface{}
+		if err := n.Decode(&x); err != nil {
+			return nil, err
+		}
+		switch x := x.(type) {
+		case int:
+			return json.Number(strconv.Itoa(x)), nil
+		case int64:
+			return json.Number(strconv.FormatInt(x, 10)), nil
+		case uint64:
+			return json.Number(strconv.FormatUint(x, 10)), nil
+		case float64:
+			if math.IsInf(x, 0) || math.IsNaN(x) {
+				return nil, nodeError(n, "number %s cannot be represented in JSON", n.Value)
+			}
+			return json.Number(strconv.FormatFloat(x, 'g', -1, 64)), nil
+		}
+		return n.Value, nil
+	default:
+		return n.Value, nil
+	}
+}
+
+func nodeError(n *yaml.Node, format string, args ...interface{}) error {
+	return &DecodeError{
+		Line:   n.Line,
+		Column: n.Column,
+		Err:    fmt.Errorf(format, args...),
+	}
+}
+
+// yamlNode converts a value to a YAML node attaching comments
+func yamlNode(x interface{}, c *Comments, ptr string) *yaml.Node {
+	var n *yaml.Node
+	switch x := x.(type) {
+	case Map:
+		n = &yaml.Node{
+			Kind:    yaml.MappingNode,
+			Tag:     "!!map",
+			Content: make([]*yaml.Node, 0, 2*len(x)),
+		}
+		if len(x) == 0 {
+			n.Style = yaml.FlowStyle
+		}
+		for i := range x {
+			key, _ := x[i].Key.(string)
+			p := pointerKey(ptr, key)
+			k := &yaml.Node{
+				Kind:  yaml.ScalarNode,
+				Tag:   "!!str",
+				Value: key,
+			}
+			v := yamlNode(x[i].Value, c, p)
+			if c != nil {
+				c.Keys[p].attach(k)
+				// Block collections start on the next line
+				if v.Kind != yaml.ScalarNode && v.Style == 0 && k.LineComment == "" {
+					k.LineComment, v.LineComment = v.LineComment, ""
+				}
+			}
+			n.Content = append(n.Content, k, v)
+		}
+	case []interface{}:
+		n = &yaml.Node{
+			Kind:    yaml.SequenceNode,
+			Tag:     "!!seq",
+			Content: make([]*yaml.Node, len(x)),
+		}
+		if len(x) == 0 {
+			n.Style = yaml.FlowStyle
+		}
+		for i, v := range x {
+			n.Content[i] = yamlNode(v, c, pointerIndex(ptr, i))
+		}
+	case string:
+		n = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: x}
+	case json.Number:
+		n = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: x.String()}
+		if _, err := x.Int64(); err == nil {
+			n.Tag = "!!int"
+		}
+	case bool:
+		n = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(x)}
+	default:
+		n = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	}
+	if c != nil {
+		c.Values[ptr].attach(n)
+	}
+	return n
+}
+
+// YAMLDocument converts a value to a YAML document node attaching comments
+func YAMLDocument(v RawValue, c *Comments) (*yaml.Node, error) {
+	var x interface{}
+	if v != "" {
+		var err error
+		if x, err = decodeRawValue(v); err != nil {
+			return nil, err
+		}
+	}
+	doc := &yaml.Node{
+		Kind:    yaml.DocumentNode,
+		Content: []*yaml.Node{yamlNode(x, c, "")},
+	}
+	if c != nil {
+		c.Comment.attach(doc)
+	}
+	return doc, nil
+}
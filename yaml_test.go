@@ -0,0 +1,38 @@
+package ycat_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alxarch/ycat"
+)
+
+func TestYAMLDecoder(t *testing.T) {
+	tests := []struct {
+		YAML      string
+		wantValue ycat.RawValue
+		wantErr   bool
+	}{
+		{"foo: bar # comment", `{"foo":"bar"}`, false},
+		{"b: 1\na: 2", `{"b":1,"a":2}`, false},
+		{"a: 0x1F", `{"a":31}`, false},
+		{"a: 1.0", `{"a":1.0}`, false},
+		{"a: .inf", ``, true},
+		{"a: &a {x: 1, y: 2}\nb: {<<: *a, y: 3}", `{"a":{"x":1,"y":2},"b":{"x":1,"y":3}}`, false},
+		{"a: &a [1]\nb: *a", `{"a":[1],"b":[1]}`, false},
+		{"a: <b>", `{"a":"<b>"}`, false},
+		{"1: foo", `{"1":"foo"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.YAML, func(t *testing.T) {
+			var v ycat.RawValue
+			dec := ycat.NewDecoder(strings.NewReader(tt.YAML), ycat.YAML)
+			if err := dec.Decode(&v); (err != nil) != tt.wantErr {
+				t.Fatalf("yamlDecoder.Decode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if v != tt.wantValue {
+				t.Errorf("yamlDecoder.Decode() %q != %q", v, tt.wantValue)
+			}
+		})
+	}
+}
@@ -1,5 +1,7 @@
 // Usefull functions
-// Completely inefficient :) should be ported to nativeFuncs
+// String helpers use native Go functions (see native.go) unless a function is passed
+local native(name) = std.native(name);
+local isNative(s, cutset) = std.isString(s) && !std.isFunction(cutset);
 local result(input, arr) =
     if std.isString(input) && std.isArray(arr) then std.join('', arr) else arr
     ;
@@ -34,6 +36,7 @@ local trimFunc(cutset) =
     ;
 std + {
     local _ = self
+    , meta:: std.extVar('__meta') // Source file, format, document and line of the input value
     , len:: std.length
     , has:: has
     , get(obj, key, v=null)::
@@ -59,6 +62,7 @@ std + {
         };
         result(arr, std.foldl(take, arr, {ok:: true, out:: []}).out)
     , indexOf(arr, x)::
+        if std.isString(arr) || std.isArray(arr) then native('indexOf')(arr, x) else
         local fn(y) = x != y;
         local n = std.length(_.takeWhile(fn, arr));
         if n == std.length(arr) then -1 else n
@@ -69,6 +73,7 @@ std + {
         local sz = std.min(size, std.length(arr));
         result(arr, std.makeArray(sz, function(i) arr[i]))
     , rev(arr):: // Reverse array
+        if std.isString(arr) then native('rev')(arr) else
         local size = std.length(arr);
         local n = size - 1;
         result(arr, std.makeArray(size, function(i) arr[n-i]))
@@ -86,6 +91,7 @@ std + {
         , isSpace(c):: c == " " || c == "\n" || c == "\t" || c == "\r"
     }
     , squeeze(s, cutset)::
+        if isNative(s, cutset) then native('squeeze')(s, cutset) else
         local tr = trimFunc(cutset);
         local fn(acc, c) =
             local n = std.length(acc) - 1;
@@ -97,6 +103,7 @@ std + {
         result(s, ss)
 
     , normalize(s):: // Trim and consolidate sequential whitespace to ' '
+        if std.isString(s) then native('normalize')(s) else
         local toSpace(c) = if _.ascii.isSpace(c) then ' ' else c;
         local ls = _.skipWhile(" ", _.map(toSpace, s));
         local rs = _.skipWhile(" ", _.rev(ls));
@@ -104,15 +111,18 @@ std + {
         result(s, ss)
 
     , trimLeft(s, cutset=_.ascii.space):: // Trim left side of a string
+        if isNative(s, cutset) then native('trimLeft')(s, cutset) else
         local tr = trimFunc(cutset);
         _.skipWhile(tr, s)
 
     , trimRight(s, cutset=_.ascii.space):: // Trim right side of a string
+        if isNative(s, cutset) then native('trimRight')(s, cutset) else
         local tr = trimFunc(cutset);
         local rs = _.skipWhile(tr, _.rev(s));
         local ls = _.rev(rs);
         result(s, ls)
     , trim(s, cutset=_.ascii.space):: // Trim both sides of a string
+        if isNative(s, cutset) then native('trim')(s, cutset) else
         local tr = trimFunc(cutset);
         local rs = _.skipWhile(tr, _.rev(s));
         local ls = _.skipWhile(tr, _.rev(rs));
@@ -131,6 +141,7 @@ std + {
             else
                 {metadata+: {namespace: n}} + res
         , name(s):: // convert string to kubernetes name
+            if std.isString(s) then native('k8sName')(s, _.k8s.maxNameSize) else
             local fn(c) =
                 if _.ascii.isLower(c) then c
                 else if _.ascii.isDigit(c) then c